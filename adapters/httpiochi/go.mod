@@ -0,0 +1,10 @@
+module github.com/pechorka/httpio/adapters/httpiochi
+
+go 1.25.0
+
+replace github.com/pechorka/httpio => ../..
+
+require (
+	github.com/go-chi/chi/v5 v5.2.1
+	github.com/pechorka/httpio v0.0.0-00010101000000-000000000000
+)
@@ -0,0 +1,13 @@
+// Package httpiochi reads httpio path fields from github.com/go-chi/chi routes.
+// It is a separate module, so httpio itself doesn't depend on chi.
+package httpiochi
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/pechorka/httpio"
+)
+
+// WithURLParams makes path fields read chi URL params of the matched route.
+func WithURLParams() httpio.UnmarshalerOption {
+	return httpio.WithPathLookuper(httpio.PathParamLookuper(chi.URLParam))
+}
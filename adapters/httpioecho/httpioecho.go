@@ -0,0 +1,22 @@
+// Package httpioecho decodes github.com/labstack/echo requests with httpio.
+// It is a separate module, so httpio itself doesn't depend on echo.
+package httpioecho
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/pechorka/httpio"
+)
+
+// Unmarshal decodes request of c into dst with u. Echo keeps path params
+// in c rather than in the request, so they are copied with r.SetPathValue first,
+// where path fields find them with the default lookup.
+func Unmarshal[T any](c echo.Context, u *httpio.Unmarshaler[T], dst *T) error {
+	r := c.Request()
+	values := c.ParamValues()
+	for i, name := range c.ParamNames() {
+		if i < len(values) {
+			r.SetPathValue(name, values[i])
+		}
+	}
+	return u.Unmarshal(r, dst)
+}
@@ -0,0 +1,39 @@
+package httpioecho_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pechorka/httpio"
+	"github.com/pechorka/httpio/adapters/httpioecho"
+)
+
+func TestUnmarshal(t *testing.T) {
+	type input struct {
+		ID    int    `path:"id"`
+		Slug  string `path:"slug"`
+		Draft *bool  `path:"draft"`
+		Page  int    `query:"page"`
+	}
+
+	unmarshaler, err := httpio.NewUnmarshaler[input]()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got input
+	e := echo.New()
+	e.GET("/posts/:id/:slug", func(c echo.Context) error {
+		return httpioecho.Unmarshal(c, unmarshaler, &got)
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/posts/42/hello?page=2", nil))
+
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body)
+	}
+	if got.ID != 42 || got.Slug != "hello" || got.Draft != nil || got.Page != 2 {
+		t.Fatalf("unexpected %+v", got)
+	}
+}
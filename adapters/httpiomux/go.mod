@@ -0,0 +1,10 @@
+module github.com/pechorka/httpio/adapters/httpiomux
+
+go 1.25.0
+
+replace github.com/pechorka/httpio => ../..
+
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/pechorka/httpio v0.0.0-00010101000000-000000000000
+)
@@ -0,0 +1,13 @@
+// Package httpiomux reads httpio path fields from github.com/gorilla/mux routes.
+// It is a separate module, so httpio itself doesn't depend on mux.
+package httpiomux
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/pechorka/httpio"
+)
+
+// WithVars makes path fields read mux route variables, fetched once per request.
+func WithVars() httpio.UnmarshalerOption {
+	return httpio.WithPathValues(mux.Vars)
+}
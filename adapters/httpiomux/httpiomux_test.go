@@ -0,0 +1,37 @@
+package httpiomux_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pechorka/httpio"
+	"github.com/pechorka/httpio/adapters/httpiomux"
+)
+
+func TestWithVars(t *testing.T) {
+	type input struct {
+		ID    int    `path:"id"`
+		Slug  string `path:"slug"`
+		Draft *bool  `path:"draft"`
+	}
+
+	unmarshaler, err := httpio.NewUnmarshaler[input](httpiomux.WithVars())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got input
+	router := mux.NewRouter()
+	router.HandleFunc("/posts/{id}/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		if err := unmarshaler.Unmarshal(r, &got); err != nil {
+			t.Fatal(err)
+		}
+	}).Methods("GET")
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/posts/42/hello", nil))
+
+	if got.ID != 42 || got.Slug != "hello" || got.Draft != nil {
+		t.Fatalf("unexpected %+v", got)
+	}
+}
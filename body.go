@@ -0,0 +1,360 @@
+package httpio
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// BodyError is returned when request body can't be read or decoded,
+// to tell body problems apart from problems with other sources.
+type BodyError struct {
+	// MediaType from Content-Type header, empty when it is absent
+	MediaType string
+	Err       error
+}
+
+func (e *BodyError) Error() string {
+	if e.MediaType == "" {
+		return fmt.Sprintf("body: %v", e.Err)
+	}
+	return fmt.Sprintf("%s body: %v", e.MediaType, e.Err)
+}
+
+func (e *BodyError) Unwrap() error {
+	return e.Err
+}
+
+func defaultBodyDecoders() map[string]BodyDecoderFunc {
+	return map[string]BodyDecoderFunc{
+		"application/json": decodeJSON,
+		"application/xml":  decodeXML,
+		"text/xml":         decodeXML,
+	}
+}
+
+func decodeJSON(body io.Reader, dst any) error {
+	return json.NewDecoder(body).Decode(dst)
+}
+
+func configuredJSONDecoder(configure func(dec *json.Decoder)) BodyDecoderFunc {
+	return func(body io.Reader, dst any) error {
+		dec := json.NewDecoder(body)
+		configure(dec)
+		return dec.Decode(dst)
+	}
+}
+
+func decodeXML(body io.Reader, dst any) error {
+	return xml.NewDecoder(body).Decode(dst)
+}
+
+func (d *Decoder) decodeBody(r *http.Request, root reflect.Value) error {
+	if d.skipBody || r.Body == nil {
+		return nil
+	}
+	var (
+		mt     string
+		decode BodyDecoderFunc
+	)
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if d.strictCT {
+			mt = ct
+		} else {
+			mt, _, _ = mime.ParseMediaType(ct)
+		}
+		decode = d.bodyDecoders[mt]
+	}
+	if mt == "application/json" && !d.c.jsonBody {
+		// nothing to populate, e.g. query-only struct
+		decode = nil
+	}
+	if decode == nil && d.c.rawBodyField == nil {
+		return nil
+	}
+	if err := d.readBody(r, root, mt, decode); err != nil {
+		return &BodyError{MediaType: mt, Err: err}
+	}
+	return nil
+}
+
+// readBody decodes r.Body with decode, which is nil when only raw body field is set.
+func (d *Decoder) readBody(r *http.Request, root reflect.Value, mt string, decode BodyDecoderFunc) error {
+	rawField := d.c.rawBodyField
+	if d.maxBodyBytes > 0 {
+		r.Body = http.MaxBytesReader(nil, r.Body, d.maxBodyBytes)
+	}
+
+	var body io.Reader = r.Body
+	decompressed := false
+	if d.decompression {
+		var err error
+		body, decompressed, err = d.decompress(r)
+		if err != nil {
+			return err
+		}
+	}
+	useDiscriminator := decode != nil && d.discriminator != nil && mt == "application/json"
+	var raw []byte
+	if d.bufferBody || useDiscriminator || rawField != nil {
+		var err error
+		raw, err = io.ReadAll(body)
+		if err != nil {
+			return fmt.Errorf("read body: %w", bodyReadError(err))
+		}
+		// raw body field is read regardless of content type,
+		// so the body is restored for other sources, e.g. form
+		if d.bufferBody || rawField != nil {
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(raw))
+			if decompressed {
+				r.Header.Del("Content-Encoding")
+			}
+		}
+		if useDiscriminator {
+			if err := d.discriminator.prepare(raw, root); err != nil {
+				return err
+			}
+		}
+		body = io.NopCloser(bytes.NewReader(raw))
+	}
+
+	if decode != nil {
+		if err := decode(body, root.Addr().Interface()); err != nil && !errors.Is(err, io.EOF) {
+			return bodyReadError(err)
+		}
+	}
+
+	if rawField != nil {
+		fieldV := fieldByIndexAlloc(root, rawField.idx)
+		if fieldV.Kind() == reflect.String {
+			fieldV.SetString(string(raw))
+		} else {
+			fieldV.SetBytes(bytes.Clone(raw))
+		}
+	}
+	return nil
+}
+
+// hasJSONBodyFields reports whether JSON body can populate any field of t:
+// fields with json tag and fields without source tags, which encoding/json matches by name.
+// Fields bound to other sources are not expected in the body unless they have json tag.
+func hasJSONBodyFields(t reflect.Type) bool {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		embedded := sf.Anonymous && derefType(sf.Type).Kind() == reflect.Struct
+		if sf.PkgPath != "" && !embedded {
+			continue
+		}
+		if jsonTag, ok := sf.Tag.Lookup("json"); ok {
+			if jsonTag != "-" {
+				return true
+			}
+			continue
+		}
+		if sf.Tag.Get("body") != "" || hasSourceTag(sf) {
+			continue
+		}
+		if embedded {
+			// fields of untagged embedded structs are promoted
+			if hasJSONBodyFields(derefType(sf.Type)) {
+				return true
+			}
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+func hasSourceTag(sf reflect.StructField) bool {
+	if sf.Tag.Get("from") != "" {
+		return true
+	}
+	if chain, _ := sourceChain(sf); chain != "" {
+		return true
+	}
+	_, _, _, ok, err := findTag(sf)
+	return ok || err != nil
+}
+
+// defaultMaxDecompressedBytes limits decompressed body when WithMaxBodyBytes is not set.
+const defaultMaxDecompressedBytes = 32 << 20
+
+// decompress wraps r.Body according to its Content-Encoding.
+// Decompressed size is limited to protect against compression bombs.
+func (d *Decoder) decompress(r *http.Request) (io.Reader, bool, error) {
+	var (
+		zr  io.ReadCloser
+		err error
+	)
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return r.Body, false, nil
+	case "gzip", "x-gzip":
+		zr, err = gzip.NewReader(r.Body)
+	case "deflate":
+		// deflate content coding is zlib format, see RFC 9110
+		zr, err = zlib.NewReader(r.Body)
+	default:
+		return nil, false, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("decompress body: %w", bodyReadError(err))
+	}
+
+	limit := d.maxBodyBytes
+	if limit <= 0 {
+		limit = defaultMaxDecompressedBytes
+	}
+	return http.MaxBytesReader(nil, zr, limit), true, nil
+}
+
+// bodyReadError makes body size limit errors stand out from decoding errors.
+func bodyReadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("request body exceeds %d bytes: %w", tooLarge.Limit, err)
+	}
+	return err
+}
+
+// checkRawBodyField accepts `body:"raw"` fields of []byte or string types.
+func checkRawBodyField(tag string, ft reflect.Type) error {
+	if tag != "raw" {
+		return fmt.Errorf("unknown body tag %q, only raw is supported", tag)
+	}
+	if ft.Kind() == reflect.String || (ft.Kind() == reflect.Slice && ft.Elem().Kind() == reflect.Uint8) {
+		return nil
+	}
+	return fmt.Errorf("raw body requires []byte or string type, got %v", ft)
+}
+
+type unionField struct {
+	idx         []int
+	jsonName    string
+	structField string // structName.fieldName for error messages
+}
+
+type discriminator struct {
+	key     string
+	mapping map[string]reflect.Type
+	fields  []unionField
+}
+
+// compileDiscriminator collects top level interface fields of t
+// and checks that every mapped type can be stored in at least one of them.
+func compileDiscriminator(t reflect.Type, key string, mapping map[string]reflect.Type) (*discriminator, error) {
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("type %s is not a struct", t.Name())
+	}
+
+	d := &discriminator{key: key, mapping: mapping}
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if sf.PkgPath != "" || sf.Type.Kind() != reflect.Interface {
+			continue
+		}
+		name := sf.Name
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		d.fields = append(d.fields, unionField{
+			idx:         sf.Index,
+			jsonName:    name,
+			structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+		})
+	}
+
+	for value, mt := range mapping {
+		if mt == nil {
+			return nil, fmt.Errorf("discriminator value %q is mapped to nil type", value)
+		}
+		assignable := false
+		for _, f := range d.fields {
+			if reflect.PointerTo(mt).AssignableTo(t.FieldByIndex(f.idx).Type) {
+				assignable = true
+				break
+			}
+		}
+		if !assignable {
+			return nil, fmt.Errorf("discriminator value %q: *%v does not implement any interface field", value, mt)
+		}
+	}
+
+	return d, nil
+}
+
+// prepare peeks discriminator values in raw body and stores pointers to concrete types
+// in interface fields, so the following body decode fills them in place.
+func (d *discriminator) prepare(raw []byte, dstStruct reflect.Value) error {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil
+	}
+
+	var objects map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &objects); err != nil {
+		return err
+	}
+
+	for _, f := range d.fields {
+		obj, ok := lookupJSONKey(objects, f.jsonName)
+		if !ok {
+			continue
+		}
+
+		var peek map[string]json.RawMessage
+		if err := json.Unmarshal(obj, &peek); err != nil || peek == nil {
+			continue
+		}
+		rawValue, ok := peek[d.key]
+		if !ok {
+			return fmt.Errorf("field %s: missing discriminator %q", f.structField, d.key)
+		}
+		var value string
+		if err := json.Unmarshal(rawValue, &value); err != nil {
+			return fmt.Errorf("field %s: discriminator %q must be a string", f.structField, d.key)
+		}
+		mt, ok := d.mapping[value]
+		if !ok {
+			return fmt.Errorf("field %s: unknown discriminator value %q", f.structField, value)
+		}
+
+		fieldV := fieldByIndexAlloc(dstStruct, f.idx)
+		concrete := reflect.New(mt)
+		if !concrete.Type().AssignableTo(fieldV.Type()) {
+			return fmt.Errorf("field %s: %v does not implement %v", f.structField, concrete.Type(), fieldV.Type())
+		}
+		fieldV.Set(concrete)
+	}
+
+	return nil
+}
+
+// lookupJSONKey mirrors encoding/json key matching: exact match first, then case-insensitive.
+func lookupJSONKey(objects map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	if v, ok := objects[name]; ok {
+		return v, true
+	}
+	for k, v := range objects {
+		if strings.EqualFold(k, name) {
+			return v, true
+		}
+	}
+	return nil, false
+}
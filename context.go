@@ -0,0 +1,60 @@
+package httpio
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// unmarshalContext binds `ctx:"name"` fields from request context values.
+// Values assignable to the field are stored as is,
+// strings and fmt.Stringer values go through the regular setters.
+func unmarshalContext(
+	r *http.Request,
+	fields map[string]compiledField,
+	st *decodeState,
+	keys map[string]any,
+) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	ctx := r.Context()
+	for name, cf := range fields {
+		val := ctx.Value(keys[name])
+		if val == nil {
+			continue
+		}
+
+		rv := reflect.ValueOf(val)
+		if rv.Type().AssignableTo(cf.typ) || (cf.isPtr && rv.Type().AssignableTo(cf.typ.Elem())) {
+			st.assign(cf, rv)
+			continue
+		}
+
+		var s string
+		var ok bool
+		switch v := val.(type) {
+		case string:
+			s, ok = v, true
+		case fmt.Stringer:
+			s, ok = v.String(), true
+		}
+		if !ok || cf.set == nil {
+			var vals []string
+			if ok {
+				vals = []string{s}
+			}
+			err := st.fieldError(cf, vals, fmt.Errorf("value of type %T is not assignable to %v", val, cf.typ))
+			if err := st.fail(err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := st.set(cf, []string{s}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
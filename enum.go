@@ -0,0 +1,36 @@
+package httpio
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// enumParsers holds parse functions registered by RegisterEnum, keyed by type.
+var enumParsers = &sync.Map{}
+
+// RegisterEnum makes values of type T parsed with parse,
+// e.g. generated ParseColor(string) (Color, error) functions of enum types,
+// so they don't need to implement encoding.TextUnmarshaler.
+// It applies to T fields, pointers to T and slices of T in all string based sources.
+//
+// Parsers are looked up when a type is compiled, so register them
+// before the first NewUnmarshaler call for types that use T, e.g. in init.
+func RegisterEnum[T any](parse func(string) (T, error)) {
+	enumParsers.Store(reflect.TypeFor[T](), func(v reflect.Value, s string) error {
+		parsed, err := parse(s)
+		if err != nil {
+			return fmt.Errorf("parse %v: %w", reflect.TypeFor[T](), err)
+		}
+		v.Set(reflect.ValueOf(parsed))
+		return nil
+	})
+}
+
+func lookupEnumParser(ft reflect.Type) (func(reflect.Value, string) error, bool) {
+	parse, ok := enumParsers.Load(ft)
+	if !ok {
+		return nil, false
+	}
+	return parse.(func(reflect.Value, string) error), true
+}
@@ -0,0 +1,84 @@
+package httpio
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Source of a field value in the request.
+type Source string
+
+const (
+	SourceQuery  Source = "query"
+	SourceForm   Source = "form"
+	SourcePath   Source = "path"
+	SourceHeader Source = "header"
+	// SourceCookie values of missing cookies are absent, like missing query keys,
+	// so defaults, required modifiers and from tag fallbacks apply to them.
+	// Earlier versions failed Unmarshal with "cookie ... is invalid" instead.
+	SourceCookie Source = "cookie"
+	SourceInject Source = "inject"
+	SourceMeta   Source = "meta"
+	SourceCtx    Source = "ctx"
+	// SourceNone is used with WithDefaultSource to skip untagged fields.
+	SourceNone Source = "none"
+)
+
+// FieldInfo describes a compiled field, e.g. for API docs generation.
+type FieldInfo struct {
+	Source Source
+	// Name is external name of the field, e.g. query key or canonical header name.
+	Name string
+	// StructField is structName.fieldName of the destination field.
+	StructField string
+	Type        reflect.Type
+	Required    bool
+	Pointer     bool
+}
+
+// Fields returns compiled fields ordered by source, then by name.
+func (d *Decoder) Fields() []FieldInfo {
+	if d.c == nil {
+		return nil
+	}
+
+	fields := make([]FieldInfo, 0, d.c.fieldCount)
+	for _, src := range allSources {
+		start := len(fields)
+		for _, cf := range d.c.sourceFields(src) {
+			fields = append(fields, cf.info())
+		}
+		if src == tagTypeQuery {
+			for _, cf := range d.c.queryPairFields {
+				fields = append(fields, cf.info())
+			}
+			for _, cf := range d.c.queryValuesFields {
+				fields = append(fields, cf.info())
+			}
+			for _, cf := range d.c.querySliceFields {
+				fields = append(fields, cf.info())
+			}
+		}
+		if src == tagTypeHeader {
+			for _, cf := range d.c.headerValuesFields {
+				fields = append(fields, cf.info())
+			}
+		}
+		slices.SortFunc(fields[start:], func(a, b FieldInfo) int {
+			return strings.Compare(a.Name, b.Name)
+		})
+	}
+	return fields
+}
+
+func (cf compiledField) info() FieldInfo {
+	return FieldInfo{
+		Source:      Source(cf.src.String()),
+		Name:        cf.name,
+		StructField: cf.structField,
+		Type:        cf.typ,
+		Required:    cf.required,
+		Pointer:     cf.isPtr,
+	}
+}
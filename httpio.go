@@ -1,33 +1,185 @@
 package httpio
 
 import (
+	"database/sql"
 	"encoding"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"net/url"
 	"reflect"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode/utf8"
 )
 
 const defaultDelimiter = "."
 
+// ErrMissingRequired is returned when a field with required modifier is absent from the request.
+var ErrMissingRequired = errors.New("required value is missing")
+
+// ErrTooManyValues is returned when a slice, map or struct slice field receives more values than WithQueryArrayLimit allows.
+var ErrTooManyValues = errors.New("too many values")
+
+// DecodeError describes a field that failed to decode, for structured logging.
+// It is returned only with WithDecodeErrorDetails, its message matches the default errors.
+type DecodeError struct {
+	Method string
+	Path   string
+	Source Source
+	// Param is external name of the field, e.g. query key or header name.
+	Param string
+	// Value is the offending value, values of slice fields are joined with ",".
+	// It is empty for missing required values and context values that aren't strings.
+	Value string
+	Err   error
+	field string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s: %v", e.field, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+var errUnsupportedType = errors.New("unsupported type")
+
+// errValueAbsent is returned by setters to treat present value as absent,
+// e.g. Authorization header with another scheme for bearer modifier.
+var errValueAbsent = errors.New("value is absent")
+
+// Validator is implemented by types that check themselves after decoding,
+// e.g. cross field rules tags can't express. Unmarshal calls Validate
+// once all fields are populated and returns its error.
+type Validator interface {
+	Validate() error
+}
+
 type PathLookuperFunc func(r *http.Request, name string) (string, bool)
 
+// PathValuesFunc returns all path values of the request at once,
+// for routers where extracting them one by one is expensive.
+type PathValuesFunc func(r *http.Request) map[string]string
+
+// BodyDecoderFunc decodes request body into dst.
+// io.EOF is treated as an empty body and ignored.
+type BodyDecoderFunc func(body io.Reader, dst any) error
+
+// FieldHookFunc is called for every field set from the request with values it was set from.
+// rawValue must not be retained after the call returns.
+type FieldHookFunc func(field FieldInfo, rawValue []string)
+
+// UnknownFieldFunc is called for query keys that are not bound to any field.
+type UnknownFieldFunc func(key string, vals []string)
+
+// Decoder is the engine behind Unmarshaler without its type parameter,
+// for frameworks and generated code that hold reflect values rather than *T.
+type Decoder struct {
+	t              reflect.Type
+	c              *compiledType
+	pathLookuper   PathLookuperFunc
+	pathValues     PathValuesFunc
+	bodyDecoders   map[string]BodyDecoderFunc
+	discriminator  *discriminator
+	queryKeyPrefix keyPrefix
+	contextKeys    map[string]any
+	bestEffort     bool
+	fieldHook      FieldHookFunc
+	requireAllPath bool
+	emptyAsAbsent  bool
+	errorDetails   bool
+	mergeHeaders   bool
+	meta           metaOptions
+	reset          bool
+	unknownQuery   UnknownFieldFunc
+	arraySuffix    bool
+	bufferBody     bool
+	skipBody       bool
+	rawQueryScan   bool
+	bracketNesting bool
+	maxBodyBytes   int64
+	decompression  bool
+	strictCT       bool
+}
+
 type Unmarshaler[T any] struct {
-	c            *compiledType
-	pathLookuper PathLookuperFunc
+	Decoder
+	afterBind func(*T, *http.Request) error
 }
 
 type UnmarshalerOptions struct {
 	// PathLookuper to get path values
 	PathLookuper PathLookuperFunc
-	Delimiter    string
+	// PathValues to get all path values at once, takes precedence over PathLookuper
+	PathValues PathValuesFunc
+	Delimiter  string
+	// BodyDecoders by media type
+	BodyDecoders map[string]BodyDecoderFunc
+	// Discriminator is JSON key used to pick concrete types for interface fields
+	Discriminator string
+	// DiscriminatorMapping from discriminator value to concrete type
+	DiscriminatorMapping map[string]reflect.Type
+	// RequireAllPath treats every path field as required
+	RequireAllPath bool
+	// IncomingKeyPrefix is stripped from query keys before matching
+	IncomingKeyPrefix string
+	// AllowUnprefixedKeys matches query keys without IncomingKeyPrefix as is
+	AllowUnprefixedKeys bool
+	// ContextKeys map ctx tag names to context keys
+	ContextKeys map[string]any
+	// BestEffort keeps decoding past field errors
+	BestEffort bool
+	// Reset zeroes destination before decoding
+	Reset bool
+	// EmptyValuesAsAbsent ignores empty values, e.g. ?q=, as if they were not sent
+	EmptyValuesAsAbsent bool
+	// QueryArrayLimit caps number of values of query and header slice fields, 0 means no limit
+	QueryArrayLimit int
+	// DecodeErrorDetails returns field errors as *DecodeError
+	DecodeErrorDetails bool
+	// HeaderCaseMerging merges values of header keys differing only in case
+	HeaderCaseMerging bool
+	// AllowMethodOverride makes meta:"method" honor X-HTTP-Method-Override of POST requests
+	AllowMethodOverride bool
+	// TrustedProxies is number of proxies whose X-Forwarded-For entries meta:"client_ip" skips
+	TrustedProxies int
+	// UnknownFieldCollector receives query keys not bound to any field
+	UnknownFieldCollector UnknownFieldFunc
+	// FieldHook is called after every field set from the request
+	FieldHook FieldHookFunc
+	// BufferBody restores request body after decoding, so it can be read again
+	BufferBody bool
+	// SkipBody disables body decoding
+	SkipBody bool
+	// MaxBodyBytes limits size of the body read by decoders, 0 means no limit
+	MaxBodyBytes int64
+	// StrictContentType decodes body only when Content-Type is exactly a registered media type
+	StrictContentType bool
+	// Decompression decodes gzip and deflate Content-Encoding of the body
+	Decompression bool
+	// BracketNesting matches query keys like a[b][c] to nested fields
+	BracketNesting bool
+	// BracketArraySuffix matches query keys like tags[] to fields named tags
+	BracketArraySuffix bool
+	// SliceStructSupport binds indexed query keys like items[0].name to struct slices
+	SliceStructSupport bool
+	// RawQueryScan parses raw query directly into fields, see WithRawQueryScan
+	RawQueryScan bool
+	// DefaultSource of untagged fields, SourceNone skips them
+	DefaultSource Source
+	// CanonicalHeaders canonicalizes header tag names and matches headers case-insensitively
+	CanonicalHeaders bool
+	// AfterBind is func(*T, *http.Request) error called after successful Unmarshal
+	AfterBind any
 }
 
 type UnmarshalerOption func(o *UnmarshalerOptions)
@@ -35,6 +187,24 @@ type UnmarshalerOption func(o *UnmarshalerOptions)
 func WithPathLookuper(lookuper PathLookuperFunc) UnmarshalerOption {
 	return func(o *UnmarshalerOptions) {
 		o.PathLookuper = lookuper
+		o.PathValues = nil
+	}
+}
+
+// WithPathValues replaces per name path lookups with a single call per request.
+// It overrides WithPathLookuper, whichever option comes last wins.
+func WithPathValues(values PathValuesFunc) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.PathValues = values
+	}
+}
+
+// WithRequireAllPath makes every path field required, so a path value
+// missing from the route, e.g. because of a typo in the tag, fails Unmarshal
+// with ErrMissingRequired. Path sources of from tags stay optional.
+func WithRequireAllPath() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.RequireAllPath = true
 	}
 }
 
@@ -44,6 +214,277 @@ func WithDelimiter(delimiter string) UnmarshalerOption {
 	}
 }
 
+// WithBodyDecoder registers decoder for request bodies with given media type.
+// Passing nil decoder disables body decoding for that media type.
+func WithBodyDecoder(mediaType string, decoder BodyDecoderFunc) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		if decoder == nil {
+			delete(o.BodyDecoders, mediaType)
+			return
+		}
+		o.BodyDecoders[mediaType] = decoder
+	}
+}
+
+// WithFieldHook calls hook after every field successfully set from the request,
+// e.g. to log which parameters were present. Defaults and context values
+// assigned as is don't trigger it.
+func WithFieldHook(hook FieldHookFunc) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.FieldHook = hook
+	}
+}
+
+// WithUnknownFieldCollector calls collector for every query key not bound to any field,
+// e.g. to find clients still sending deprecated parameters. Keys are reported as sent,
+// including incoming key prefix. Keys captured by query:"*" field count as unknown too,
+// unless they match other fields. It disables WithRawQueryScan.
+func WithUnknownFieldCollector(collector UnknownFieldFunc) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.UnknownFieldCollector = collector
+	}
+}
+
+// WithReset zeroes destination before decoding, so values reused across requests,
+// e.g. from a sync.Pool, don't keep fields of previous requests absent from the new one.
+func WithReset() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.Reset = true
+	}
+}
+
+// WithQueryArrayLimit fails Unmarshal with ErrTooManyValues when a query or header
+// slice field receives more than n values, e.g. ?id=1&id=2&...&id=100000,
+// before the slice is allocated. Values of csv and sep fields are counted as split,
+// so ?ids=1,2,...,100000 is limited too. Scalar fields are not affected.
+// Map fields, e.g. `query:"*"` and `header:"X-Custom-*"`, and pair fields are limited
+// per key, struct slice fields by number of elements.
+func WithQueryArrayLimit(n int) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.QueryArrayLimit = n
+	}
+}
+
+// WithHeaderCaseMerging merges values of r.Header keys differing only in case,
+// e.g. "X-Tag" and "x-tag" left by transports that don't canonicalize names:
+// values of the canonical key go first, then values of the others sorted by key.
+// Such requests get a canonicalized copy of r.Header, other requests are not affected.
+// It has no effect with WithHeaderCanonicalization(false).
+func WithHeaderCaseMerging() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.HeaderCaseMerging = true
+	}
+}
+
+// WithDecodeErrorDetails returns field errors as *DecodeError holding request method and path,
+// field source, name and offending value, e.g. for error middleware to log them.
+// It is opt-in, as errors then retain request values.
+func WithDecodeErrorDetails() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.DecodeErrorDetails = true
+	}
+}
+
+// WithAllowMethodOverride makes `meta:"method"` fields get the method from X-HTTP-Method-Override
+// header, e.g. PATCH tunneled through POST. Only POST requests are overridden, as the header
+// exists for clients that can't send other methods. Without the header r.Method is used.
+func WithAllowMethodOverride() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.AllowMethodOverride = true
+	}
+}
+
+// WithTrustedProxies makes `meta:"client_ip"` fields trust X-Forwarded-For and X-Real-IP
+// headers set by n proxies in front of the server. The client is the n-th X-Forwarded-For
+// entry from the end, as each proxy appends address of its peer, e.g. with n = 2 and
+// "client, proxy1" the client is the first entry. Without the option client_ip is
+// the host of r.RemoteAddr, as headers sent by clients can't be trusted.
+func WithTrustedProxies(n int) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.TrustedProxies = n
+	}
+}
+
+// WithEmptyValuesAsAbsent ignores empty values, so ?q= is handled like absent q:
+// default modifier applies, required one fails and pointers stay nil.
+// By default ?q= is present, sets string fields to "" and satisfies required.
+// It applies to every source, e.g. empty form values, headers and cookies too.
+func WithEmptyValuesAsAbsent() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.EmptyValuesAsAbsent = true
+	}
+}
+
+// WithJSONOptions lets configure decoder of application/json bodies before decoding,
+// e.g. to call DisallowUnknownFields or UseNumber.
+// It replaces decoder registered for application/json with WithBodyDecoder.
+func WithJSONOptions(configure func(dec *json.Decoder)) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.BodyDecoders["application/json"] = configuredJSONDecoder(configure)
+	}
+}
+
+// WithStrictContentType decodes body only when Content-Type header is exactly
+// one of registered media types, e.g. "application/json; charset=utf-8" is not decoded.
+// By default parameters are ignored and media type is matched case-insensitively.
+func WithStrictContentType() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.StrictContentType = true
+	}
+}
+
+// WithDiscriminator enables decoding JSON body objects into interface fields.
+// Value of field key inside the object is looked up in mapping,
+// and the body is decoded into a new value of the resulting type.
+func WithDiscriminator(field string, mapping map[string]reflect.Type) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.Discriminator = field
+		o.DiscriminatorMapping = mapping
+	}
+}
+
+// WithIncomingKeyPrefix strips prefix from incoming query keys before matching them to fields.
+// Keys without the prefix are ignored, unless allowUnprefixed is set.
+// When both prefixed and unprefixed keys are present, prefixed one wins.
+func WithIncomingKeyPrefix(prefix string, allowUnprefixed bool) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.IncomingKeyPrefix = prefix
+		o.AllowUnprefixedKeys = allowUnprefixed
+	}
+}
+
+// WithContextKey maps name used in `ctx:"name"` tags to the actual context key.
+func WithContextKey(name string, key any) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		if o.ContextKeys == nil {
+			o.ContextKeys = map[string]any{}
+		}
+		o.ContextKeys[name] = key
+	}
+}
+
+// WithBestEffort makes Unmarshal continue past errors, populating every field it can,
+// and return all errors joined at the end.
+// Note that dst may be partially populated when Unmarshal returns non-nil error.
+func WithBestEffort() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.BestEffort = true
+	}
+}
+
+// WithBufferedBody makes Unmarshal read the whole body into memory before decoding
+// and replace r.Body with a fresh reader over the same bytes afterwards,
+// so downstream handlers can read it again.
+// The tradeoff is memory: the entire body is held for the lifetime of the request,
+// so consider limiting its size, e.g. with http.MaxBytesReader.
+func WithBufferedBody() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.BufferBody = true
+	}
+}
+
+// WithoutBody disables body decoding, leaving r.Body untouched
+// for handlers that manage the body themselves.
+func WithoutBody() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.SkipBody = true
+	}
+}
+
+// WithMaxBodyBytes limits request body read by body decoders and form parsing to n bytes
+// by wrapping r.Body in http.MaxBytesReader.
+// Exceeding the limit fails Unmarshal with an error wrapping *http.MaxBytesError.
+func WithMaxBodyBytes(n int64) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.MaxBodyBytes = n
+	}
+}
+
+// WithDecompression makes body decoders read bodies with gzip or deflate Content-Encoding
+// through a decompressor. Other encodings fail Unmarshal.
+// Decompressed size is limited by WithMaxBodyBytes, or by 32 MiB when it is not set,
+// exceeding it fails Unmarshal with an error wrapping *http.MaxBytesError.
+func WithDecompression() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.Decompression = true
+	}
+}
+
+// WithBracketArraySuffix makes query keys with PHP style [] suffix, e.g. tags[]=a&tags[]=b,
+// match fields named without it. When both tags and tags[] are sent, their values are merged,
+// values of tags go first. It disables WithRawQueryScan.
+func WithBracketArraySuffix() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.BracketArraySuffix = true
+	}
+}
+
+// WithSliceStructSupport makes tagged query fields of struct slice types, e.g.
+// Items []Item `query:"items"`, bind indexed keys like items[0].name and items[1].qty.
+// Elements are compiled like nested structs with only query fields, and indices
+// must be contiguous from 0. Without the option such fields fail NewUnmarshaler
+// as unsupported, and untagged ones are left to the body decoder either way.
+func WithSliceStructSupport() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.SliceStructSupport = true
+	}
+}
+
+// WithBracketNesting makes query keys in bracket notation, e.g. name[first]=John
+// or a[b][c]=1, match nested fields just like their delimited form name.first.
+// When both forms of the same key are present, the delimited one wins.
+func WithBracketNesting() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.BracketNesting = true
+	}
+}
+
+// WithRawQueryScan makes Unmarshal scan r.URL.RawQuery directly into fields
+// instead of building url.Values first, which saves allocations.
+// Semantics differ for repeated keys: scalar fields take the last value
+// instead of the first one, and every occurrence has to be valid.
+// Structs with url.Values fields, as well as WithIncomingKeyPrefix
+// and WithBracketNesting, fall back to the regular query decoding.
+func WithRawQueryScan() UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.RawQueryScan = true
+	}
+}
+
+// WithDefaultSource sets source of untagged exported fields, SourceQuery by default.
+// SourceNone skips untagged fields like unexported ones,
+// though untagged embedded structs are still promoted.
+// Only sources looked up by name are supported: query, form, path, header and cookie.
+func WithDefaultSource(src Source) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.DefaultSource = src
+	}
+}
+
+// WithHeaderCanonicalization controls how header tag names are matched.
+// By default names are canonicalized and headers are matched case-insensitively,
+// so lowercase keys of HTTP/2 transports that don't canonicalize r.Header work too.
+// When r.Header has several keys differing only in case, the canonical one is used,
+// see WithHeaderCaseMerging to merge their values instead.
+// Passing false keeps names exactly as written in tags and requires
+// header keys in r.Header to match them byte for byte.
+func WithHeaderCanonicalization(canonicalize bool) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.CanonicalHeaders = canonicalize
+	}
+}
+
+// WithAfterBind registers fn called once at the end of Unmarshal,
+// after all sources are bound and validated without errors. Error returned by fn fails Unmarshal.
+// T must match the type of Unmarshaler, otherwise NewUnmarshaler returns an error.
+func WithAfterBind[T any](fn func(dst *T, r *http.Request) error) UnmarshalerOption {
+	return func(o *UnmarshalerOptions) {
+		o.AfterBind = fn
+	}
+}
+
+// MustNewUnmarshaler is like NewUnmarshaler but panics if T can't be compiled,
+// for package-level variables, e.g. var decoder = httpio.MustNewUnmarshaler[Input]().
 func MustNewUnmarshaler[T any](userOpts ...UnmarshalerOption) *Unmarshaler[T] {
 	u, err := NewUnmarshaler[T](userOpts...)
 	if err != nil {
@@ -54,21 +495,113 @@ func MustNewUnmarshaler[T any](userOpts ...UnmarshalerOption) *Unmarshaler[T] {
 }
 
 func NewUnmarshaler[T any](userOpts ...UnmarshalerOption) (*Unmarshaler[T], error) {
+	opts := newOptions(userOpts)
+	d, err := newDecoder(reflect.TypeFor[T](), opts)
+	if err != nil {
+		return nil, err
+	}
+	var afterBind func(*T, *http.Request) error
+	if opts.AfterBind != nil {
+		var ok bool
+		afterBind, ok = opts.AfterBind.(func(*T, *http.Request) error)
+		if !ok {
+			var zero T
+			return nil, fmt.Errorf("after bind hook %T doesn't match type %T", opts.AfterBind, zero)
+		}
+	}
+	return &Unmarshaler[T]{Decoder: *d, afterBind: afterBind}, nil
+}
+
+// NewDecoder is like NewUnmarshaler for type t known only at run time.
+// WithAfterBind is not supported, as its hook is typed.
+func NewDecoder(t reflect.Type, userOpts ...UnmarshalerOption) (*Decoder, error) {
+	opts := newOptions(userOpts)
+	if opts.AfterBind != nil {
+		return nil, errors.New("after bind hook is supported only by NewUnmarshaler")
+	}
+	return newDecoder(t, opts)
+}
+
+func newOptions(userOpts []UnmarshalerOption) *UnmarshalerOptions {
 	opts := &UnmarshalerOptions{
-		PathLookuper: defaultPathLookuper,
-		Delimiter:    defaultDelimiter,
+		PathLookuper:     defaultPathLookuper,
+		Delimiter:        defaultDelimiter,
+		BodyDecoders:     defaultBodyDecoders(),
+		CanonicalHeaders: true,
+		DefaultSource:    SourceQuery,
 	}
 	for _, opt := range userOpts {
 		opt(opts)
 	}
-	compiledType, err := compileType[T](opts.Delimiter)
+	return opts
+}
+
+func newDecoder(t reflect.Type, opts *UnmarshalerOptions) (*Decoder, error) {
+	if opts.Delimiter == "" {
+		return nil, errors.New("delimiter must not be empty")
+	}
+	defaultSource := tagTypeNone
+	if opts.DefaultSource != SourceNone {
+		src, ok := sourceByName(string(opts.DefaultSource))
+		if !ok || !slices.Contains(fallbackSources, src) {
+			return nil, fmt.Errorf("unsupported default source %q", opts.DefaultSource)
+		}
+		defaultSource = src
+	}
+	compiledType, err := compileType(t, compileOptions{
+		delimiter:     opts.Delimiter,
+		exactHeaders:  !opts.CanonicalHeaders,
+		defaultSource: defaultSource,
+		sliceStructs:  opts.SliceStructSupport,
+		arrayLimit:    opts.QueryArrayLimit,
+	})
 	if err != nil {
-		var zero T
-		return nil, fmt.Errorf("failed to compile type %T: %w", zero, err)
+		return nil, fmt.Errorf("failed to compile type %v: %w", t, err)
+	}
+	for name, cf := range compiledType.contextFields {
+		if _, ok := opts.ContextKeys[name]; !ok {
+			return nil, fmt.Errorf("failed to compile type %v: field %s: no context key registered for %q", t, cf.structField, name)
+		}
+	}
+	var disc *discriminator
+	if opts.Discriminator != "" {
+		disc, err = compileDiscriminator(t, opts.Discriminator, opts.DiscriminatorMapping)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile type %v: %w", t, err)
+		}
 	}
-	return &Unmarshaler[T]{
-		c:            compiledType,
-		pathLookuper: opts.PathLookuper,
+	return &Decoder{
+		t:              t,
+		c:              compiledType,
+		pathLookuper:   opts.PathLookuper,
+		pathValues:     opts.PathValues,
+		bodyDecoders:   opts.BodyDecoders,
+		discriminator:  disc,
+		contextKeys:    opts.ContextKeys,
+		bestEffort:     opts.BestEffort,
+		fieldHook:      opts.FieldHook,
+		requireAllPath: opts.RequireAllPath,
+		emptyAsAbsent:  opts.EmptyValuesAsAbsent,
+		errorDetails:   opts.DecodeErrorDetails,
+		mergeHeaders:   opts.HeaderCaseMerging,
+		reset:          opts.Reset,
+		unknownQuery:   opts.UnknownFieldCollector,
+		arraySuffix:    opts.BracketArraySuffix,
+		bufferBody:     opts.BufferBody,
+		skipBody:       opts.SkipBody,
+		rawQueryScan:   opts.RawQueryScan,
+		bracketNesting: opts.BracketNesting,
+		maxBodyBytes:   opts.MaxBodyBytes,
+		decompression:  opts.Decompression,
+		strictCT:       opts.StrictContentType,
+		queryKeyPrefix: keyPrefix{
+			prefix:          opts.IncomingKeyPrefix,
+			allowUnprefixed: opts.AllowUnprefixedKeys,
+		},
+		meta: metaOptions{
+			methodOverride: opts.AllowMethodOverride,
+			trustedProxies: opts.TrustedProxies,
+		},
 	}, nil
 }
 
@@ -77,6 +610,24 @@ func defaultPathLookuper(r *http.Request, name string) (string, bool) {
 	return v, len(v) > 0
 }
 
+// PathParamLookuper adapts router helpers of form func(r, name) string,
+// such as chi.URLParam, to PathLookuperFunc. Empty value is treated as not found:
+//
+//	httpio.WithPathLookuper(httpio.PathParamLookuper(chi.URLParam))
+//
+// Routers returning all params as a map, such as gorilla/mux, fit WithPathValues as is:
+//
+//	httpio.WithPathValues(mux.Vars)
+//
+// Ready made options for chi, gorilla/mux and echo live in separate modules
+// under adapters, so httpio doesn't depend on any router.
+func PathParamLookuper(param func(r *http.Request, name string) string) PathLookuperFunc {
+	return func(r *http.Request, name string) (string, bool) {
+		v := param(r, name)
+		return v, len(v) > 0
+	}
+}
+
 type tagType int
 
 const (
@@ -86,30 +637,263 @@ const (
 	tagTypeHeader
 	tagTypeCookie
 	tagTypeForm
+	tagTypeInject
+	tagTypeMeta
+	tagTypeContext
 )
 
+func (t tagType) String() string {
+	switch t {
+	case tagTypeQuery:
+		return "query"
+	case tagTypePath:
+		return "path"
+	case tagTypeHeader:
+		return "header"
+	case tagTypeCookie:
+		return "cookie"
+	case tagTypeForm:
+		return "form"
+	case tagTypeInject:
+		return "inject"
+	case tagTypeMeta:
+		return "meta"
+	case tagTypeContext:
+		return "ctx"
+	}
+	return "none"
+}
+
+var allSources = []tagType{
+	tagTypeQuery,
+	tagTypeForm,
+	tagTypePath,
+	tagTypeHeader,
+	tagTypeCookie,
+	tagTypeInject,
+	tagTypeMeta,
+	tagTypeContext,
+}
+
 type valueSetterFunc func(v reflect.Value, vals []string) error
 
 type compiledField struct {
+	id          int // position in compiledType, used to track provided fields
 	idx         []int
 	set         valueSetterFunc
 	isPtr       bool
 	structField string // structName.fieldName for error messages
+	name        string // external name
+	src         tagType
+	typ         reflect.Type
+	required    bool
+	// defaultValue is set when the field was not provided
+	defaultValue *string
+	// fallback fields share id with other entries of a `from` tag,
+	// rank is position in the tag, the lowest present rank wins.
+	fallback bool
+	rank     int
+	// unmappedOnly makes `query:"*"` field skip keys bound to other query fields
+	unmappedOnly bool
+	// elem holds query fields of struct slice elements, see unmarshalQuerySlices
+	elem *compiledType
+	// pairs fields get flattened key/value pairs, see makePairsSetter and makeValuesSetter
+	pairs bool
+}
+
+// describe names the field for decode errors by its external name first,
+// as that is what API clients send, e.g. `query parameter "age" (input.Age)`.
+func (cf compiledField) describe() string {
+	var kind string
+	switch cf.src {
+	case tagTypeQuery:
+		kind = "query parameter"
+	case tagTypeForm:
+		kind = "form field"
+	case tagTypePath:
+		kind = "path parameter"
+	case tagTypeHeader:
+		kind = "header"
+	case tagTypeCookie:
+		kind = "cookie"
+	case tagTypeInject:
+		kind = "injected value"
+	case tagTypeMeta:
+		kind = "request meta"
+	case tagTypeContext:
+		kind = "context value"
+	default:
+		return "field " + cf.structField
+	}
+	return fmt.Sprintf("%s %q (%s)", kind, cf.name, cf.structField)
 }
 
 type compiledType struct {
-	queryFields  map[string]compiledField
-	formFields   map[string]compiledField
-	pathFields   map[string]compiledField
-	headerFields map[string]compiledField
-	cookieFields map[string]compiledField
+	delimiter string
+	// exactHeaders keeps header names as written in tags and matches them exactly
+	exactHeaders  bool
+	defaultSource tagType
+	queryFields   map[string]compiledField
+	sliceStructs  bool // compiles tagged query struct slices into querySliceFields
+	arrayLimit    int  // caps values of query and header slice fields
+	// queryPairFields are keyed by name prefix, see makePairsSetter
+	queryPairFields map[string]compiledField
+	// queryValuesFields are keyed by name prefix, see makeValuesSetter
+	queryValuesFields map[string]compiledField
+	// querySliceFields are struct slices keyed by name, see unmarshalQuerySlices
+	querySliceFields map[string]compiledField
+	formFields       map[string]compiledField
+	pathFields       map[string]compiledField
+	headerFields     map[string]compiledField
+	// headerValuesFields are keyed by wildcard name, e.g. "X-Custom-*", see unmarshalHeader
+	headerValuesFields map[string]compiledField
+	cookieFields       map[string]compiledField
+	injectFields       map[string]compiledField
+	metaFields         map[string]compiledField
+	contextFields      map[string]compiledField
+
+	// rawBodyField receives the whole request body, see checkRawBodyField
+	rawBodyField *compiledField
+
+	fieldCount   int
+	required     []compiledField
+	defaults     []compiledField
+	hasFallbacks bool
+	// jsonBody is set when JSON body can populate any field, see hasJSONBodyFields
+	jsonBody bool
+	// validator is set when pointer to the type implements Validator
+	validator bool
+}
+
+// addField fails when another field already uses the same external name in fields,
+// as one of them would silently never be set.
+func (c *compiledType) addField(fields map[string]compiledField, cf compiledField) error {
+	if prev, ok := fields[cf.name]; ok {
+		return fmt.Errorf("%s %q is used by both %s and %s", cf.src, cf.name, prev.structField, cf.structField)
+	}
+	cf.id = c.fieldCount
+	c.fieldCount++
+	if cf.required {
+		c.required = append(c.required, cf)
+	}
+	if cf.defaultValue != nil {
+		c.defaults = append(c.defaults, cf)
+	}
+	fields[cf.name] = cf
+	return nil
+}
+
+// addFallbackFields registers cf once per source of a `from` tag, all sharing the same id.
+func (c *compiledType) addFallbackFields(cf compiledField, chain []fallbackSource) error {
+	c.hasFallbacks = true
+	cf.fallback = true
+	for i, fs := range chain {
+		cf.rank = i
+		cf.src = fs.src
+		cf.name = fs.name
+		if fs.src == tagTypeHeader && !c.exactHeaders {
+			cf.name = http.CanonicalHeaderKey(fs.name)
+		}
+		fields := c.sourceFields(fs.src)
+		if i == 0 {
+			if err := c.addField(fields, cf); err != nil {
+				return err
+			}
+			cf.id = c.fieldCount - 1
+			continue
+		}
+		if prev, ok := fields[cf.name]; ok {
+			return fmt.Errorf("%s %q is used by both %s and %s", cf.src, cf.name, prev.structField, cf.structField)
+		}
+		fields[cf.name] = cf
+	}
+	return nil
+}
+
+// lookupFields finds fields by external names across all sources.
+func (c *compiledType) lookupFields(names []string) ([]compiledField, error) {
+	fields := make([]compiledField, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, src := range allSources {
+			if cf, ok := c.sourceFields(src)[name]; ok {
+				fields = append(fields, cf)
+				found = true
+			}
+		}
+		if cf, ok := c.headerFields[http.CanonicalHeaderKey(name)]; ok && name != cf.name {
+			fields = append(fields, cf)
+			found = true
+		}
+		if cf, ok := c.queryPairFields[name]; ok {
+			fields = append(fields, cf)
+			found = true
+		}
+		if cf, ok := c.queryValuesFields[name]; ok {
+			fields = append(fields, cf)
+			found = true
+		}
+		if cf, ok := c.querySliceFields[name]; ok {
+			fields = append(fields, cf)
+			found = true
+		}
+		if cf, ok := c.headerValuesFields[name]; ok {
+			fields = append(fields, cf)
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+	}
+	return fields, nil
+}
+
+func (c *compiledType) sourceFields(src tagType) map[string]compiledField {
+	switch src {
+	case tagTypeQuery:
+		return c.queryFields
+	case tagTypeForm:
+		return c.formFields
+	case tagTypePath:
+		return c.pathFields
+	case tagTypeHeader:
+		return c.headerFields
+	case tagTypeCookie:
+		return c.cookieFields
+	case tagTypeInject:
+		return c.injectFields
+	case tagTypeMeta:
+		return c.metaFields
+	case tagTypeContext:
+		return c.contextFields
+	}
+	return nil
+}
+
+// compileKey holds everything that affects compilation,
+// so the same type compiled with different options gets separate cache entries.
+type compileKey struct {
+	t    reflect.Type
+	opts compileOptions
+}
+
+// compileOptions are UnmarshalerOptions that affect compilation.
+type compileOptions struct {
+	delimiter    string
+	exactHeaders bool
+	// defaultSource is used for untagged fields, tagTypeNone skips them
+	defaultSource tagType
+	// sliceStructs compiles tagged query struct slices, see WithSliceStructSupport
+	sliceStructs bool
+	// arrayLimit caps values of query and header slice fields, see WithQueryArrayLimit
+	arrayLimit int
 }
 
 var compiledTypeCache = &sync.Map{}
 
-func compileType[T any](delimiter string) (*compiledType, error) {
-	t := reflect.TypeFor[T]()
-	if cached, ok := compiledTypeCache.Load(t); ok {
+func compileType(t reflect.Type, opts compileOptions) (*compiledType, error) {
+	key := compileKey{t: t, opts: opts}
+	if cached, ok := compiledTypeCache.Load(key); ok {
 		return cached.(*compiledType), nil
 	}
 
@@ -118,295 +902,1818 @@ func compileType[T any](delimiter string) (*compiledType, error) {
 		return nil, fmt.Errorf("type %s is not a struct", t.Name())
 	}
 
-	c := &compiledType{
-		queryFields:  map[string]compiledField{},
-		formFields:   map[string]compiledField{},
-		pathFields:   map[string]compiledField{},
-		headerFields: map[string]compiledField{},
-		cookieFields: map[string]compiledField{},
+	c := newCompiledType(opts)
+	if err := walkType(t, nil, nil, opts.delimiter, c); err != nil {
+		return nil, err
 	}
-	walkType(t, nil, nil, delimiter, c)
+	c.jsonBody = hasJSONBodyFields(t)
+	c.validator = reflect.PointerTo(t).Implements(reflect.TypeFor[Validator]())
 
-	compiledTypeCache.Store(t, c)
+	compiledTypeCache.Store(key, c)
 
 	return c, nil
 }
 
+func newCompiledType(opts compileOptions) *compiledType {
+	return &compiledType{
+		delimiter:          opts.delimiter,
+		exactHeaders:       opts.exactHeaders,
+		defaultSource:      opts.defaultSource,
+		sliceStructs:       opts.sliceStructs,
+		arrayLimit:         opts.arrayLimit,
+		queryFields:        map[string]compiledField{},
+		queryPairFields:    map[string]compiledField{},
+		queryValuesFields:  map[string]compiledField{},
+		querySliceFields:   map[string]compiledField{},
+		formFields:         map[string]compiledField{},
+		pathFields:         map[string]compiledField{},
+		headerFields:       map[string]compiledField{},
+		headerValuesFields: map[string]compiledField{},
+		cookieFields:       map[string]compiledField{},
+		injectFields:       map[string]compiledField{},
+		metaFields:         map[string]compiledField{},
+		contextFields:      map[string]compiledField{},
+	}
+}
+
+// compileSliceElem compiles struct slice element of t into query fields
+// named relative to the element, e.g. "name" for items[0].name.
+// One level of nesting is supported, so elements can't have struct slices of their own.
+func compileSliceElem(t reflect.Type, out *compiledType) (*compiledType, error) {
+	elem := newCompiledType(compileOptions{
+		delimiter:     out.delimiter,
+		exactHeaders:  out.exactHeaders,
+		defaultSource: out.defaultSource,
+		sliceStructs:  out.sliceStructs,
+		arrayLimit:    out.arrayLimit,
+	})
+	if err := walkType(t.Elem(), nil, nil, out.delimiter, elem); err != nil {
+		return nil, err
+	}
+	if len(elem.queryFields) != elem.fieldCount || elem.hasFallbacks || elem.rawBodyField != nil {
+		return nil, fmt.Errorf("slice element %v: only query fields are supported", t.Elem())
+	}
+	return elem, nil
+}
+
 func walkType(
 	t reflect.Type,
 	pathPrefix []string,
 	idxPrefix []int,
 	delimiter string,
 	out *compiledType,
-) {
+) error {
+	var errs []error
 	for i := range t.NumField() {
 		sf := t.Field(i)
-		if sf.PkgPath != "" { // unexported
+		// exported fields of unexported embedded structs are still promoted
+		if sf.PkgPath != "" && !(sf.Anonymous && sf.Type.Kind() == reflect.Struct) { // unexported
 			continue
 		}
 
-		name, src, ok := findTag(sf)
+		tag, modSep, src, ok, err := findTag(sf)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("field %s.%s: %w", t.Name(), sf.Name, err))
+			continue
+		}
+		if bodyTag := sf.Tag.Get("body"); bodyTag != "" && sf.PkgPath == "" {
+			switch {
+			case ok:
+				errs = append(errs, fmt.Errorf("field %s.%s: body tag can't be combined with %s tag", t.Name(), sf.Name, src))
+			case out.rawBodyField != nil:
+				errs = append(errs, fmt.Errorf("field %s.%s: raw body is already bound to %s", t.Name(), sf.Name, out.rawBodyField.structField))
+			default:
+				if err := checkRawBodyField(bodyTag, sf.Type); err != nil {
+					errs = append(errs, fmt.Errorf("field %s.%s: %w", t.Name(), sf.Name, err))
+					continue
+				}
+				out.rawBodyField = &compiledField{
+					idx:         append(slices.Clone(idxPrefix), sf.Index...),
+					structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+					typ:         sf.Type,
+				}
+			}
+			continue
+		}
+		from := sf.Tag.Get("from")
+		if chain, _ := sourceChain(sf); chain != "" {
+			from = chain
+		}
+		if from != "" && sf.PkgPath == "" {
+			chain, fopts, err := parseFromTag(from)
+			var set valueSetterFunc
+			if slices.ContainsFunc(chain, func(fs fallbackSource) bool {
+				return fs.src == tagTypeQuery || fs.src == tagTypeHeader
+			}) {
+				fopts.maxValues = out.arrayLimit
+			}
+			if err == nil {
+				set, err = makeFieldSetter(sf.Type, fopts)
+			}
+			if err == nil {
+				err = out.addFallbackFields(compiledField{
+					idx:          append(slices.Clone(idxPrefix), sf.Index...),
+					set:          set,
+					isPtr:        sf.Type.Kind() == reflect.Pointer,
+					structField:  fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+					typ:          sf.Type,
+					required:     fopts.required,
+					defaultValue: fopts.defaultValue,
+				}, chain)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("field %s.%s: %w", t.Name(), sf.Name, err))
+			}
+			continue
+		}
 		if !ok {
+			src = out.defaultSource
+		}
+
+		// untagged embedded structs are promoted like in encoding/json: no name prefix
+		if !ok && sf.Anonymous {
+			embedded := sf.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if isStructExpandable(embedded) {
+				idx := append(slices.Clone(idxPrefix), sf.Index...)
+				if err := walkType(embedded, pathPrefix, idx, delimiter, out); err != nil {
+					errs = append(errs, err)
+				}
+				continue
+			}
+		}
+		if sf.PkgPath != "" || src == tagTypeNone {
+			continue
+		}
+		name, fopts, err := parseTag(tag, modSep)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("field %s.%s: %w", t.Name(), sf.Name, err))
+			continue
+		}
+		if name == "" {
 			name = sf.Name
-			src = tagTypeQuery
 		}
 
 		path := append(slices.Clone(pathPrefix), name)
 		idx := append(slices.Clone(idxPrefix), sf.Index...)
 
-		under := sf.Type
-		isPtr := under.Kind() == reflect.Pointer
-		if isPtr {
-			under = under.Elem()
+		if src == tagTypeInject {
+			if err := checkInjectField(name, sf.Type); err != nil {
+				errs = append(errs, fmt.Errorf("field %s.%s: %w", t.Name(), sf.Name, err))
+				continue
+			}
+			if err := out.addField(out.injectFields, compiledField{
+				idx:         idx,
+				isPtr:       sf.Type.Kind() == reflect.Pointer,
+				structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+				name:        name,
+				src:         src,
+				typ:         sf.Type,
+				required:    fopts.required,
+			}); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if src == tagTypeQuery && isPairSlice(sf.Type) {
+			if err := out.addField(out.queryPairFields, compiledField{
+				idx:         idx,
+				set:         makePairsSetter(sf.Type),
+				pairs:       true,
+				structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+				name:        strings.Join(path, delimiter),
+				src:         src,
+				typ:         sf.Type,
+				required:    fopts.required,
+			}); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if fopts.unmapped && (src != tagTypeQuery || name != catchAllName) {
+			errs = append(errs, fmt.Errorf("field %s.%s: unmapped modifier requires query:\"*\" tag", t.Name(), sf.Name))
+			continue
+		}
+		if (src == tagTypeQuery || src == tagTypeForm) && name == catchAllName && !isValuesMap(sf.Type) {
+			errs = append(errs, fmt.Errorf("field %s.%s: %s:\"*\" requires map[string][]string type, got %v", t.Name(), sf.Name, src, sf.Type))
+			continue
+		}
+
+		if src == tagTypeForm && name == catchAllName {
+			// whole form, nesting doesn't apply
+			if err := out.addField(out.formFields, compiledField{
+				idx:         idx,
+				set:         makeValuesSetter(sf.Type),
+				pairs:       true,
+				structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+				name:        catchAllName,
+				src:         src,
+				typ:         sf.Type,
+				required:    fopts.required,
+			}); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		if src == tagTypeQuery && isValuesMap(sf.Type) && !fopts.json {
+			prefix := strings.Join(path, delimiter)
+			if name == catchAllName {
+				// catch-all captures every key, nesting doesn't apply
+				prefix = catchAllName
+			}
+			if err := out.addField(out.queryValuesFields, compiledField{
+				idx:          idx,
+				set:          makeValuesSetter(sf.Type),
+				pairs:        true,
+				structField:  fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+				name:         prefix,
+				src:          src,
+				typ:          sf.Type,
+				required:     fopts.required,
+				unmappedOnly: fopts.unmapped,
+			}); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		// untagged struct slices are left to the body decoder
+		if ok && src == tagTypeQuery && out.sliceStructs && sf.Type.Kind() == reflect.Slice && isStructExpandable(sf.Type.Elem()) && !fopts.json {
+			elem, err := compileSliceElem(sf.Type, out)
+			if err == nil {
+				err = out.addField(out.querySliceFields, compiledField{
+					idx:         idx,
+					structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+					name:        strings.Join(path, delimiter),
+					src:         src,
+					typ:         sf.Type,
+					required:    fopts.required,
+					elem:        elem,
+				})
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("field %s.%s: %w", t.Name(), sf.Name, err))
+			}
+			continue
+		}
+
+		if src == tagTypeHeader && strings.HasSuffix(name, catchAllName) {
+			if !isValuesMap(sf.Type) {
+				errs = append(errs, fmt.Errorf("field %s.%s: header:%q requires map[string][]string type, got %v", t.Name(), sf.Name, name, sf.Type))
+				continue
+			}
+			prefix := strings.TrimSuffix(strings.Join(path, delimiter), catchAllName)
+			if !out.exactHeaders {
+				prefix = http.CanonicalHeaderKey(prefix)
+			}
+			if err := out.addField(out.headerValuesFields, compiledField{
+				idx:         idx,
+				set:         makeValuesSetter(sf.Type),
+				pairs:       true,
+				structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+				name:        prefix + catchAllName,
+				src:         src,
+				typ:         sf.Type,
+				required:    fopts.required,
+			}); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		under := sf.Type
+		isPtr := under.Kind() == reflect.Pointer
+		if isPtr {
+			under = under.Elem()
+		}
+
+		// context values are stored as is and json values are decoded whole,
+		// so structs are not expanded
+		if src != tagTypeContext && !fopts.json && isStructExpandable(under) {
+			if fopts.required {
+				errs = append(errs, fmt.Errorf("field %s.%s: required modifier is not supported on nested structs", t.Name(), sf.Name))
+				continue
+			}
+			childPath, childDelimiter := path, delimiter
+			if fopts.delim != "" {
+				// names inside are joined by their own delimiter,
+				// so names up to this field become a single element
+				childPath = []string{strings.Join(path, delimiter)}
+				childDelimiter = fopts.delim
+			}
+			if err := walkType(under, childPath, idx, childDelimiter, out); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if fopts.delim != "" {
+			errs = append(errs, fmt.Errorf("field %s.%s: delim modifier requires a nested struct, got %v", t.Name(), sf.Name, sf.Type))
+			continue
+		}
+
+		if fopts.sep != "" && under.Kind() != reflect.Slice && under.Kind() != reflect.Array {
+			errs = append(errs, fmt.Errorf("field %s.%s: separator modifiers require a slice or an array, got %v", t.Name(), sf.Name, sf.Type))
+			continue
+		}
+
+		if src == tagTypeQuery || src == tagTypeHeader {
+			fopts.maxValues = out.arrayLimit
+		}
+		set, err := makeFieldSetter(sf.Type, fopts)
+		switch {
+		case err == nil:
+		case !ok:
+			// untagged fields of types that can't be set from strings are left to the body decoder
+			continue
+		case src == tagTypeContext && errors.Is(err, errUnsupportedType):
+			// context values can still be assigned as is
+		default:
+			errs = append(errs, fmt.Errorf("field %s.%s: %w", t.Name(), sf.Name, err))
+			continue
+		}
+
+		fullName := strings.Join(path, delimiter)
+		switch src {
+		case tagTypeHeader:
+			if !out.exactHeaders {
+				fullName = http.CanonicalHeaderKey(fullName)
+			}
+		case tagTypeMeta:
+			// meta keys are fixed, nesting doesn't apply
+			if _, ok := metaValues[name]; !ok {
+				errs = append(errs, fmt.Errorf("field %s.%s: unknown meta key %q", t.Name(), sf.Name, name))
+				continue
+			}
+			fullName = name
+		case tagTypeContext:
+			// context keys are registered by name, nesting doesn't apply
+			fullName = name
+		}
+
+		if err := out.addField(out.sourceFields(src), compiledField{
+			idx:          idx,
+			set:          set,
+			isPtr:        isPtr,
+			structField:  fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+			name:         fullName,
+			src:          src,
+			typ:          sf.Type,
+			required:     fopts.required,
+			defaultValue: fopts.defaultValue,
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func isStructExpandable(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	// Treat as scalar if it (or pointer to it) implements TextUnmarshaler.
+	if implementsTextUnmarshaler(t) || implementsTextUnmarshaler(reflect.PointerTo(t)) {
+		return false
+	}
+	// sql.Null* types and other scanners hold a single value too.
+	if implementsScanner(t) {
+		return false
+	}
+	return true
+}
+
+var (
+	timeType        = reflect.TypeFor[time.Time]()
+	nullTimeType    = reflect.TypeFor[sql.NullTime]()
+	nullTimeGeneric = reflect.TypeFor[sql.Null[time.Time]]()
+)
+
+// isTimeType reports whether t is time.Time or its sql nullable wrapper,
+// which can't Scan strings and are parsed by makeTimeSetter instead.
+func isTimeType(t reflect.Type) bool {
+	return t == timeType || t == nullTimeType || t == nullTimeGeneric
+}
+
+// makeTimeSetter parses values with layout, RFC 3339 when it is empty,
+// or as Unix seconds or milliseconds in UTC with unix and unixmilli modifiers,
+// and makes nullable wrappers Valid.
+func makeTimeSetter(ft reflect.Type, opts fieldOptions) func(v reflect.Value, s string) error {
+	layout, unit := opts.layout, opts.unixUnit
+	return func(v reflect.Value, s string) error {
+		var t time.Time
+		var err error
+		switch {
+		case unit != 0:
+			var n int64
+			n, err = strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return parseNumberError("unix time", s, ft, err)
+			}
+			if unit == time.Second {
+				t = time.Unix(n, 0).UTC()
+			} else {
+				t = time.UnixMilli(n).UTC()
+			}
+		case layout != "":
+			t, err = time.Parse(layout, s)
+		default:
+			err = t.UnmarshalText([]byte(s))
+		}
+		if err != nil {
+			return fmt.Errorf("parse time: %w", err)
+		}
+		switch ft {
+		case nullTimeType:
+			v.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+		case nullTimeGeneric:
+			v.Set(reflect.ValueOf(sql.Null[time.Time]{V: t, Valid: true}))
+		default:
+			v.Set(reflect.ValueOf(t))
+		}
+		return nil
+	}
+}
+
+// implementsScanner reports whether pointer to t implements sql.Scanner.
+func implementsScanner(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(reflect.TypeFor[sql.Scanner]())
+}
+
+func implementsTextUnmarshaler(t reflect.Type) bool {
+	if !t.Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
+		return false
+	}
+	return true
+}
+
+// isPairSlice reports whether t is a slice of structs
+// with string Key and Value fields, e.g. []struct{ Key, Value string }.
+func isPairSlice(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	for _, name := range []string{"Key", "Value"} {
+		f, ok := t.Elem().FieldByName(name)
+		if !ok || f.PkgPath != "" || f.Type.Kind() != reflect.String {
+			return false
+		}
+	}
+	return true
+}
+
+// makePairsSetter expects vals to be flattened key/value pairs: k1, v1, k2, v2...
+func makePairsSetter(ft reflect.Type) valueSetterFunc {
+	keyField, _ := ft.Elem().FieldByName("Key")
+	valueField, _ := ft.Elem().FieldByName("Value")
+	return func(v reflect.Value, vals []string) error {
+		if len(vals) == 0 {
+			return nil
+		}
+		s := reflect.MakeSlice(ft, len(vals)/2, len(vals)/2)
+		for i := range s.Len() {
+			s.Index(i).FieldByIndex(keyField.Index).SetString(vals[2*i])
+			s.Index(i).FieldByIndex(valueField.Index).SetString(vals[2*i+1])
+		}
+		v.Set(s)
+		return nil
+	}
+}
+
+// isValuesMap reports whether t is url.Values or another map[string][]string.
+func isValuesMap(t reflect.Type) bool {
+	return t.Kind() == reflect.Map &&
+		t.Key().Kind() == reflect.String &&
+		t.Elem().Kind() == reflect.Slice &&
+		t.Elem().Elem().Kind() == reflect.String
+}
+
+// makeValuesSetter expects vals to be flattened key/value pairs, like makePairsSetter.
+// Repeated keys keep all their values.
+func makeValuesSetter(ft reflect.Type) valueSetterFunc {
+	return func(v reflect.Value, vals []string) error {
+		if len(vals) == 0 {
+			return nil
+		}
+		m := make(url.Values, len(vals)/2)
+		for i := 0; i+1 < len(vals); i += 2 {
+			m.Add(vals[i], vals[i+1])
+		}
+		v.Set(reflect.ValueOf(m).Convert(ft))
+		return nil
+	}
+}
+
+// makeValueSetter returns an error for types that can't be set from strings,
+// so they are reported when the type is compiled rather than when a value arrives.
+//
+// Signed integers and floats accept optional "+" or "-" sign, "-0" is zero,
+// negative zero for floats. Unsigned integers reject signs with a clear error,
+// plus modifier lets them accept "+" prefix.
+func makeValueSetter(ft reflect.Type, opts fieldOptions) (valueSetterFunc, error) {
+	if ft.Kind() == reflect.Pointer {
+		elemSet, err := makeValueSetter(ft.Elem(), opts)
+		if err != nil {
+			return nil, err
+		}
+		// Pointers tell absent values from zero ones, e.g. *bool is nil without ?active,
+		// and points to false for ?active=false. Present but empty ?active= is parsed
+		// like any other value, so it fails for *bool and sets "" for *string.
+		return func(v reflect.Value, vals []string) error {
+			if len(vals) == 0 {
+				// absent value keeps pointer nil
+				return nil
+			}
+			if !v.IsNil() {
+				return elemSet(v.Elem(), vals)
+			}
+			// allocate only once the value is parsed, so failures keep pointer nil
+			ptr := reflect.New(ft.Elem())
+			if err := elemSet(ptr.Elem(), vals); err != nil {
+				return err
+			}
+			v.Set(ptr)
+			return nil
+		}, nil
+	}
+
+	// Raw bytes of the first value, e.g. json.RawMessage to forward as is
+	if opts.raw || ft == jsonRawMessageType {
+		return func(v reflect.Value, vals []string) error {
+			if len(vals) == 0 {
+				return nil
+			}
+			v.SetBytes([]byte(vals[0]))
+			return nil
+		}, nil
+	}
+
+	// JSON of the first value, e.g. ?ids=[1,2,3] or ?filter={"age":30}.
+	// It is decoded into a new value, so failures leave the field as is.
+	if opts.json {
+		return func(v reflect.Value, vals []string) error {
+			if len(vals) == 0 {
+				return nil
+			}
+			ptr := reflect.New(ft)
+			if err := json.Unmarshal([]byte(vals[0]), ptr.Interface()); err != nil {
+				return fmt.Errorf("decode json: %w", err)
+			}
+			v.Set(ptr.Elem())
+			return nil
+		}, nil
+	}
+
+	// Bytes decoded from base64 of the first value, e.g. a binary signature
+	if opts.base64 {
+		return func(v reflect.Value, vals []string) error {
+			if len(vals) == 0 {
+				return nil
+			}
+			b, err := base64.StdEncoding.DecodeString(vals[0])
+			if err != nil {
+				return fmt.Errorf("decode base64: %w", err)
+			}
+			v.SetBytes(b)
+			return nil
+		}, nil
+	}
+
+	// Slice of scalars
+	if ft.Kind() == reflect.Slice {
+		elem := ft.Elem()
+		elemSet, err := makeElemSetter(elem, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: slice element %v", errUnsupportedType, elem)
+		}
+		return func(v reflect.Value, vals []string) error {
+			if len(vals) == 0 {
+				// leave zero value slice
+				return nil
+			}
+			if opts.maxValues > 0 {
+				// counted before splitting, so one huge csv value isn't split either
+				n := len(vals)
+				if opts.sep != "" {
+					n = countSplit(vals, opts.sep)
+				}
+				if n > opts.maxValues {
+					return fmt.Errorf("%w: got %d, limit is %d", ErrTooManyValues, n, opts.maxValues)
+				}
+			}
+			if opts.sep != "" {
+				vals = splitValues(vals, opts.sep)
+			}
+			if v.IsNil() {
+				// Grow allocates only the backing array, unlike MakeSlice followed by Set
+				v.Grow(len(vals))
+				v.SetLen(len(vals))
+				for i := range vals {
+					if err := elemSet(v.Index(i), vals[i]); err != nil {
+						v.SetZero()
+						return err
+					}
+				}
+				return nil
+			}
+			s := reflect.MakeSlice(ft, len(vals), len(vals))
+			for i := range vals {
+				if err := elemSet(s.Index(i), vals[i]); err != nil {
+					return err
+				}
+			}
+			v.Set(s)
+			return nil
+		}, nil
+	}
+
+	// Fixed size array of scalars, number of values must match array length
+	if ft.Kind() == reflect.Array {
+		elem := ft.Elem()
+		elemSet, err := makeElemSetter(elem, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%w: array element %v", errUnsupportedType, elem)
+		}
+		return func(v reflect.Value, vals []string) error {
+			if len(vals) == 0 {
+				return nil
+			}
+			if opts.sep != "" {
+				vals = splitValues(vals, opts.sep)
+			}
+			if len(vals) != ft.Len() {
+				return fmt.Errorf("expected %d values, got %d", ft.Len(), len(vals))
+			}
+			arr := reflect.New(ft).Elem()
+			for i := range vals {
+				if err := elemSet(arr.Index(i), vals[i]); err != nil {
+					return err
+				}
+			}
+			v.Set(arr)
+			return nil
+		}, nil
+	}
+
+	scalar, err := makeScalarSetter(ft, opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(v reflect.Value, vals []string) error {
+		if len(vals) == 0 {
+			return nil
+		}
+		return scalar(v, vals[0])
+	}, nil
+}
+
+// makeElemSetter is makeScalarSetter for slice and array elements,
+// which can also be pointers to scalars, e.g. []*int.
+func makeElemSetter(elem reflect.Type, opts fieldOptions) (func(reflect.Value, string) error, error) {
+	if elem.Kind() != reflect.Pointer {
+		return makeScalarSetter(elem, opts)
+	}
+	scalar, err := makeScalarSetter(elem.Elem(), opts)
+	if err != nil {
+		return nil, err
+	}
+	return func(v reflect.Value, s string) error {
+		ptr := reflect.New(elem.Elem())
+		if err := scalar(ptr.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}, nil
+}
+
+var (
+	jsonNumberType     = reflect.TypeFor[json.Number]()
+	jsonRawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// isJSONNumber reports whether s is a number literal as defined by JSON,
+// so json.Number fields keep the same guarantees as when decoded from a body.
+func isJSONNumber(s string) bool {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) {
+		return false
+	}
+	var n json.Number
+	return json.Unmarshal([]byte(s), &n) == nil
+}
+
+// countSplit returns number of values splitValues would return.
+func countSplit(vals []string, sep string) int {
+	n := 0
+	for _, val := range vals {
+		n += strings.Count(val, sep) + 1
+	}
+	return n
+}
+
+func splitValues(vals []string, sep string) []string {
+	split := make([]string, 0, len(vals))
+	for _, val := range vals {
+		split = append(split, strings.Split(val, sep)...)
+	}
+	return split
+}
+
+func makeScalarSetter(ft reflect.Type, opts fieldOptions) (func(reflect.Value, string) error, error) {
+	if opts.trim || opts.lower || opts.upper {
+		plain := opts
+		plain.trim, plain.lower, plain.upper = false, false, false
+		scalar, err := makeScalarSetter(ft, plain)
+		if err != nil {
+			return nil, err
+		}
+		return withNormalize(scalar, opts), nil
+	}
+	if parse, ok := lookupEnumParser(ft); ok {
+		return parse, nil
+	}
+	if isTimeType(ft) {
+		return makeTimeSetter(ft, opts), nil
+	}
+	if implementsTextUnmarshaler(ft) || implementsTextUnmarshaler(reflect.PointerTo(ft)) {
+		return func(v reflect.Value, s string) error {
+			// Ensure addressable pointer receiver.
+			var tu encoding.TextUnmarshaler
+			if v.CanAddr() {
+				if x, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+					tu = x
+				}
+			}
+			if tu == nil && v.CanInterface() {
+				if x, ok := v.Interface().(encoding.TextUnmarshaler); ok {
+					tu = x
+				}
+			}
+			if tu == nil {
+				return fmt.Errorf("type %v claims TextUnmarshaler but value not addressable", ft)
+			}
+			if err := tu.UnmarshalText([]byte(s)); err != nil {
+				return fmt.Errorf("unmarshal %q into %v: %w", s, ft, err)
+			}
+			return nil
+		}, nil
+	}
+
+	// Scanners get value as a string, e.g. sql.NullInt64 converts it with strconv
+	// and becomes Valid. Absent values leave them zero, i.e. not Valid.
+	if ft.Kind() == reflect.Struct && implementsScanner(ft) {
+		return func(v reflect.Value, s string) error {
+			return v.Addr().Interface().(sql.Scanner).Scan(s)
+		}, nil
+	}
+
+	if ft == jsonNumberType {
+		return func(v reflect.Value, s string) error {
+			if !isJSONNumber(s) {
+				return fmt.Errorf("%q is not a valid number", s)
+			}
+			v.SetString(s)
+			return nil
+		}, nil
+	}
+
+	if opts.char {
+		return func(v reflect.Value, s string) error {
+			r, size := utf8.DecodeRuneInString(s)
+			if size == 0 || size != len(s) || r == utf8.RuneError {
+				return fmt.Errorf("%q is not a single character", s)
+			}
+			if ft.Kind() == reflect.Uint8 {
+				if r >= utf8.RuneSelf {
+					return fmt.Errorf("%q is not an ASCII character", s)
+				}
+				v.SetUint(uint64(r))
+				return nil
+			}
+			v.SetInt(int64(r))
+			return nil
+		}, nil
+	}
+
+	switch ft.Kind() {
+	case reflect.String:
+		return func(v reflect.Value, s string) error {
+			v.SetString(s)
+			return nil
+		}, nil
+	case reflect.Bool:
+		if opts.trueValue != nil {
+			trueValue := *opts.trueValue
+			return func(v reflect.Value, s string) error {
+				v.SetBool(s == trueValue)
+				return nil
+			}, nil
+		}
+		if opts.truthy {
+			return func(v reflect.Value, s string) error {
+				b, ok := truthyValues[s]
+				if !ok {
+					b, ok = truthyValues[strings.ToLower(s)]
+				}
+				if !ok {
+					return fmt.Errorf("%q is not a truthy value", s)
+				}
+				v.SetBool(b)
+				return nil
+			}, nil
+		}
+		return func(v reflect.Value, s string) error {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return fmt.Errorf("parse bool: %w", err)
+			}
+			v.SetBool(b)
+			return nil
+		}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits, base := ft.Bits(), intBase(opts)
+		return func(v reflect.Value, s string) error {
+			i, err := strconv.ParseInt(s, base, bits)
+			if err != nil {
+				return parseNumberError("int", s, ft, err)
+			}
+			v.SetInt(i)
+			return nil
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bits, base, plus := ft.Bits(), intBase(opts), opts.plus
+		return func(v reflect.Value, s string) error {
+			if s != "" && (s[0] == '-' || s[0] == '+') {
+				if s[0] == '-' {
+					return fmt.Errorf("%q: negative value for unsigned type %v", s, ft)
+				}
+				if !plus {
+					return fmt.Errorf("%q: sign prefix for unsigned type %v, use plus modifier to allow it", s, ft)
+				}
+				s = s[1:]
+			}
+			u, err := strconv.ParseUint(s, base, bits)
+			if err != nil {
+				return parseNumberError("uint", s, ft, err)
+			}
+			v.SetUint(u)
+			return nil
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		bits := ft.Bits()
+		return func(v reflect.Value, s string) error {
+			f, err := strconv.ParseFloat(s, bits)
+			if err != nil {
+				return parseNumberError("float", s, ft, err)
+			}
+			v.SetFloat(f)
+			return nil
+		}, nil
+	case reflect.Complex64, reflect.Complex128:
+		bits := ft.Bits()
+		return func(v reflect.Value, s string) error {
+			c, err := strconv.ParseComplex(s, bits)
+			if err != nil {
+				return parseNumberError("complex", s, ft, err)
+			}
+			v.SetComplex(c)
+			return nil
+		}, nil
+	default:
+		// Named types over the above kinds work fine with Set* calls.
+		return nil, fmt.Errorf("%w: %v", errUnsupportedType, ft)
+	}
+}
+
+// truthyValues are accepted by bool fields with truthy modifier, case-insensitively.
+var truthyValues = map[string]bool{
+	"1": true, "t": true, "true": true, "on": true, "yes": true, "y": true,
+	"0": false, "f": false, "false": false, "off": false, "no": false, "n": false,
+}
+
+func intBase(opts fieldOptions) int {
+	if opts.base == nil {
+		return 10
+	}
+	return *opts.base
+}
+
+// parseNumberError replaces strconv range errors with a message naming the target type.
+func parseNumberError(kind, s string, ft reflect.Type, err error) error {
+	if errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("%q: %w for type %v", s, strconv.ErrRange, ft)
+	}
+	return fmt.Errorf("parse %s: %w", kind, err)
+}
+
+func (u *Unmarshaler[T]) Unmarshal(r *http.Request, dst *T) error {
+	if u.c == nil {
+		return fmt.Errorf("Unmarshaler is not initialized")
+	}
+
+	return u.unmarshal(r, dst, u.c.required)
+}
+
+// Decode is like Unmarshal, but allocates the value and returns it.
+func (u *Unmarshaler[T]) Decode(r *http.Request) (T, error) {
+	var v T
+	err := u.Unmarshal(r, &v)
+	return v, err
+}
+
+// defaultUnmarshalers caches *Unmarshaler[T] without options by type
+// for package-level Decode and Unmarshal.
+var defaultUnmarshalers = &sync.Map{}
+
+func defaultUnmarshaler[T any]() (*Unmarshaler[T], error) {
+	t := reflect.TypeFor[T]()
+	if cached, ok := defaultUnmarshalers.Load(t); ok {
+		return cached.(*Unmarshaler[T]), nil
+	}
+	u, err := NewUnmarshaler[T]()
+	if err != nil {
+		return nil, err
+	}
+	defaultUnmarshalers.Store(t, u)
+	return u, nil
+}
+
+// Unmarshal decodes r into dst with default options.
+// The Unmarshaler is built on first call for T and reused afterwards.
+func Unmarshal[T any](r *http.Request, dst *T) error {
+	u, err := defaultUnmarshaler[T]()
+	if err != nil {
+		return err
+	}
+	return u.Unmarshal(r, dst)
+}
+
+// Decode decodes r into a new value of T.
+// Without options the Unmarshaler is built once per type and reused, like in Unmarshal.
+// With options a new Unmarshaler is built on every call, but the compiled type
+// is still cached by type and options that affect compilation, e.g. WithDelimiter,
+// so prefer NewUnmarshaler in hot paths that need options.
+func Decode[T any](r *http.Request, opts ...UnmarshalerOption) (T, error) {
+	var zero T
+	var (
+		u   *Unmarshaler[T]
+		err error
+	)
+	if len(opts) == 0 {
+		u, err = defaultUnmarshaler[T]()
+	} else {
+		u, err = NewUnmarshaler[T](opts...)
+	}
+	if err != nil {
+		return zero, err
+	}
+	return u.Decode(r)
+}
+
+// Sources are pre-parsed request parts for UnmarshalSources.
+type Sources struct {
+	Query   url.Values
+	Header  http.Header
+	Path    map[string]string
+	Cookies []*http.Cookie
+	// Body is decoded according to Content-Type in Header, may be nil
+	Body io.Reader
+}
+
+// UnmarshalSources decodes sources without an *http.Request, e.g. for non HTTP transports and tests.
+// Path values are taken from sources.Path, ignoring WithPathLookuper and WithPathValues.
+// Sources that need a real request get values of an empty GET request, e.g. meta and ctx fields.
+func (u *Unmarshaler[T]) UnmarshalSources(dst *T, sources Sources) error {
+	if u.c == nil {
+		return fmt.Errorf("Unmarshaler is not initialized")
+	}
+
+	r, err := http.NewRequest(http.MethodGet, "/", sources.Body)
+	if err != nil {
+		return err
+	}
+	r.URL.RawQuery = sources.Query.Encode()
+	if sources.Header != nil {
+		r.Header = sources.Header.Clone()
+	}
+	for _, c := range sources.Cookies {
+		r.AddCookie(c)
+	}
+
+	withPath := *u
+	withPath.pathValues = func(*http.Request) map[string]string {
+		return sources.Path
+	}
+	return withPath.unmarshal(r, dst, u.c.required)
+}
+
+// UnmarshalRequired is like Unmarshal, but required modifiers from tags are replaced
+// with requiredFields for this call. Fields are referred to by external names, as in FieldInfo.Name.
+func (u *Unmarshaler[T]) UnmarshalRequired(r *http.Request, dst *T, requiredFields ...string) error {
+	if u.c == nil {
+		return fmt.Errorf("Unmarshaler is not initialized")
+	}
+
+	required, err := u.c.lookupFields(requiredFields)
+	if err != nil {
+		return err
+	}
+
+	return u.unmarshal(r, dst, required)
+}
+
+func (u *Unmarshaler[T]) unmarshal(r *http.Request, dst *T, required []compiledField) error {
+	if err := u.decode(r, reflect.ValueOf(dst).Elem(), required); err != nil {
+		return err
+	}
+
+	if u.afterBind != nil {
+		if err := u.afterBind(dst, r); err != nil {
+			return fmt.Errorf("after bind: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// DecodeInto decodes r into v, which must be an addressable value
+// of the struct type Decoder was built for, e.g. reflect.New(t).Elem().
+func (d *Decoder) DecodeInto(r *http.Request, v reflect.Value) error {
+	if d.c == nil {
+		return fmt.Errorf("Decoder is not initialized")
+	}
+	if !v.IsValid() || v.Type() != d.t {
+		return fmt.Errorf("DecodeInto expects value of type %v", d.t)
+	}
+	if !v.CanAddr() {
+		return errors.New("DecodeInto expects addressable value, e.g. reflect.New(t).Elem()")
+	}
+
+	return d.decode(r, v, d.c.required)
+}
+
+func (d *Decoder) decode(r *http.Request, root reflect.Value, required []compiledField) error {
+	if d.reset {
+		root.SetZero()
+	}
+	state := newDecodeState(d.c, root, required)
+	st := &state
+	st.bestEffort = d.bestEffort
+	st.fieldHook = d.fieldHook
+	st.emptyAsAbsent = d.emptyAsAbsent
+	if d.errorDetails {
+		st.req = r
+	}
+
+	if err := st.fail(d.decodeBody(r, root)); err != nil {
+		return err
+	}
+
+	sourceErrs := []error{
+		d.decodeQuery(r, st),
+		unmarshalQueryPairs(r, d.c.queryPairFields, d.c.delimiter, st, d.queryKeyPrefix),
+		unmarshalQuerySlices(r, d.c.querySliceFields, d.c.delimiter, st, d.queryKeyPrefix),
+		unmarshalForm(r, d.c.formFields, st, d.maxBodyBytes),
+		unmarshalPath(r, d.c.pathFields, st, d.pathLookuper, d.pathValues, d.requireAllPath),
+		unmarshalHeader(r, d.c.headerFields, d.c.headerValuesFields, st, d.c.exactHeaders, d.mergeHeaders),
+		unmarshalCookie(r, d.c.cookieFields, st),
+		unmarshalInject(r, d.c.injectFields, st),
+		unmarshalMeta(r, d.c.metaFields, st, d.meta),
+		unmarshalContext(r, d.c.contextFields, st, d.contextKeys),
+	}
+	for _, err := range sourceErrs {
+		if err := st.fail(err); err != nil {
+			return err
+		}
+	}
+
+	if err := st.applyDefaults(d.c.defaults); err != nil {
+		return err
+	}
+
+	if err := st.checkRequired(required); err != nil {
+		return err
+	}
+
+	if len(st.errs) > 0 {
+		return errors.Join(st.errs...)
+	}
+
+	if d.c.validator {
+		if err := root.Addr().Interface().(Validator).Validate(); err != nil {
+			return fmt.Errorf("validate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// decodeState is per call state shared by all sources.
+type decodeState struct {
+	root reflect.Value
+	// provided is indexed by compiledField.id,
+	// nil when there are no required fields to check.
+	provided []bool
+	// ranks is indexed by compiledField.id and holds rank+1 of the fallback source
+	// that set the field, nil when there are no fallback fields.
+	ranks []int
+	// bestEffort collects errors into errs instead of stopping on the first one
+	bestEffort bool
+	errs       []error
+	// fieldHook is called after successful set, nil when unused
+	fieldHook FieldHookFunc
+	// emptyAsAbsent drops empty values before they reach setters
+	emptyAsAbsent bool
+	// req is set with WithDecodeErrorDetails to describe field errors as *DecodeError
+	req *http.Request
+	// arrayLimit caps values gathered per key of map, pair and struct slice fields, see WithQueryArrayLimit
+	arrayLimit int
+}
+
+// newDecodeState returns state by value, so it stays on the stack of Unmarshal.
+func newDecodeState(c *compiledType, root reflect.Value, required []compiledField) decodeState {
+	st := decodeState{root: root, arrayLimit: c.arrayLimit}
+	if len(required) > 0 || len(c.defaults) > 0 {
+		st.provided = make([]bool, c.fieldCount)
+	}
+	if c.hasFallbacks {
+		st.ranks = make([]int, c.fieldCount)
+	}
+	return st
+}
+
+// fail returns err as is, unless decoding is best effort,
+// in which case err is recorded and decoding goes on.
+func (st *decodeState) fail(err error) error {
+	if err == nil || !st.bestEffort {
+		return err
+	}
+	st.errs = append(st.errs, err)
+	return nil
+}
+
+func (st *decodeState) set(cf compiledField, vals []string) error {
+	if st.emptyAsAbsent {
+		if cf.pairs {
+			vals = dropEmptyPairs(vals)
+		} else {
+			vals = dropEmpty(vals)
+		}
+		if len(vals) == 0 {
+			return nil
+		}
+	}
+	var prevRank int
+	if cf.fallback {
+		prevRank = st.ranks[cf.id]
+		if prevRank != 0 && prevRank <= cf.rank {
+			// already set from a source earlier in the chain
+			return nil
+		}
+		st.ranks[cf.id] = cf.rank + 1
+	}
+	var prevProvided bool
+	if st.provided != nil {
+		prevProvided = st.provided[cf.id]
+		st.provided[cf.id] = true
+	}
+	var allocated reflect.Value
+	if len(cf.idx) > 1 {
+		allocated = firstNilPointer(st.root, cf.idx)
+	}
+	fieldV := fieldByIndexAlloc(st.root, cf.idx)
+	if err := cf.set(fieldV, vals); err != nil {
+		if allocated.IsValid() {
+			// nothing was set, so pointer structs stay nil
+			allocated.SetZero()
+		}
+		if errors.Is(err, errValueAbsent) {
+			if cf.fallback {
+				st.ranks[cf.id] = prevRank
+			}
+			if st.provided != nil {
+				st.provided[cf.id] = prevProvided
+			}
+			return nil
+		}
+		return st.fail(st.fieldError(cf, vals, err))
+	}
+	if st.fieldHook != nil {
+		st.fieldHook(cf.info(), vals)
+	}
+	return nil
+}
+
+// exceedsLimit reports whether n values of key exceed WithQueryArrayLimit,
+// in which case the values must not be set and the returned error, if any, returned.
+func (st *decodeState) exceedsLimit(cf compiledField, key string, n int) (bool, error) {
+	if st.arrayLimit <= 0 || n <= st.arrayLimit {
+		return false, nil
+	}
+	cf.name = key
+	return true, st.fail(st.fieldError(cf, nil, fmt.Errorf("%w: got %d, limit is %d", ErrTooManyValues, n, st.arrayLimit)))
+}
+
+// fieldError prefixes err with the field description,
+// or wraps it in *DecodeError with WithDecodeErrorDetails.
+func (st *decodeState) fieldError(cf compiledField, vals []string, err error) error {
+	if st.req == nil {
+		return fmt.Errorf("%s: %w", cf.describe(), err)
+	}
+	return &DecodeError{
+		Method: st.req.Method,
+		Path:   st.req.URL.Path,
+		Source: Source(cf.src.String()),
+		Param:  cf.name,
+		Value:  strings.Join(vals, ","),
+		Err:    err,
+		field:  cf.describe(),
+	}
+}
+
+// fieldByIndexAlloc is like reflect.Value.FieldByIndex, but allocates nil pointers
+// to structs along the way, e.g. embedded *Pagination, instead of panicking.
+// Pointers are allocated only when a field behind them is set.
+func fieldByIndexAlloc(v reflect.Value, idx []int) reflect.Value {
+	if len(idx) == 1 {
+		return v.Field(idx[0])
+	}
+	for i, x := range idx {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// firstNilPointer returns the outermost nil pointer to struct on the path to idx,
+// which fieldByIndexAlloc would allocate, or zero Value when there is none.
+func firstNilPointer(v reflect.Value, idx []int) reflect.Value {
+	for i, x := range idx {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return v
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return reflect.Value{}
+}
+
+// dropEmpty returns vals without empty strings, copying only when there are some.
+func dropEmpty(vals []string) []string {
+	if !slices.Contains(vals, "") {
+		return vals
+	}
+	out := make([]string, 0, len(vals))
+	for _, v := range vals {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// dropEmptyPairs is dropEmpty for flattened key/value pairs,
+// keys are dropped together with their empty values.
+func dropEmptyPairs(vals []string) []string {
+	hasEmpty := false
+	for i := 1; i < len(vals); i += 2 {
+		if vals[i] == "" {
+			hasEmpty = true
+			break
+		}
+	}
+	if !hasEmpty {
+		return vals
+	}
+	out := make([]string, 0, len(vals))
+	for i := 0; i+1 < len(vals); i += 2 {
+		if vals[i+1] != "" {
+			out = append(out, vals[i], vals[i+1])
+		}
+	}
+	return out
+}
+
+// assign stores already typed value, allocating pointer fields as needed.
+func (st *decodeState) assign(cf compiledField, val reflect.Value) {
+	fieldV := fieldByIndexAlloc(st.root, cf.idx)
+	if cf.isPtr && !val.Type().AssignableTo(cf.typ) {
+		ptr := reflect.New(cf.typ.Elem())
+		ptr.Elem().Set(val)
+		val = ptr
+	}
+	fieldV.Set(val)
+	if st.provided != nil {
+		st.provided[cf.id] = true
+	}
+}
+
+func (st *decodeState) applyDefaults(defaults []compiledField) error {
+	// defaults are not present in the request
+	hook := st.fieldHook
+	st.fieldHook = nil
+	defer func() { st.fieldHook = hook }()
+	for _, cf := range defaults {
+		if st.provided[cf.id] {
+			continue
+		}
+		if err := st.set(cf, []string{*cf.defaultValue}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (st *decodeState) checkRequired(required []compiledField) error {
+	for _, cf := range required {
+		if st.provided[cf.id] {
+			continue
+		}
+		err := st.fieldError(cf, nil, ErrMissingRequired)
+		if err := st.fail(err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyPrefix strips a prefix added to incoming query keys, e.g. by a gateway.
+type keyPrefix struct {
+	prefix          string
+	allowUnprefixed bool
+}
+
+func (p keyPrefix) strip(key string) (string, bool) {
+	if p.prefix == "" {
+		return key, true
+	}
+	if stripped, ok := strings.CutPrefix(key, p.prefix); ok {
+		return stripped, true
+	}
+	return key, p.allowUnprefixed
+}
+
+// catchAllName is the name of `query:"*"` and `form:"*"` fields, which receive all values of their source.
+const catchAllName = "*"
+
+// unmarshalQuery sets fields matched by exact key,
+// and groups keys starting with prefixes of valuesFields into them.
+// Catch-all field receives every key, or with unmapped modifier
+// only keys not bound to other fields of fields and valuesFields.
+// Non empty bracketDelimiter enables bracket notation, see unbracketKey.
+// arraySuffix strips [] suffix of keys, merging values of tags[] into tags.
+// Non nil unknown is called with original and matched names of keys not mapped to fields.
+func unmarshalQuery(
+	r *http.Request,
+	fields map[string]compiledField,
+	valuesFields map[string]compiledField,
+	st *decodeState,
+	kp keyPrefix,
+	bracketDelimiter string,
+	arraySuffix bool,
+	unknown func(key, name string, vals []string),
+) error {
+	if len(fields) == 0 && len(valuesFields) == 0 && unknown == nil {
+		return nil
+	}
+
+	parsedQuery := r.URL.Query()
+	grouped := make(map[string][]string, len(valuesFields))
+
+	for key, vals := range parsedQuery {
+		if arraySuffix {
+			if base, ok := strings.CutSuffix(key, "[]"); ok && base != "" {
+				if plain, ok := parsedQuery[base]; ok {
+					vals = append(slices.Clone(plain), vals...)
+				}
+				key = base
+			} else if parsedQuery.Has(key + "[]") {
+				// merged into values of key[]
+				continue
+			}
+		}
+		name, ok := kp.strip(key)
+		if !ok {
+			if unknown != nil {
+				unknown(key, key, vals)
+			}
+			continue
+		}
+		if kp.prefix != "" && name == key && parsedQuery.Has(kp.prefix+key) {
+			// prefixed key takes precedence
+			continue
+		}
+		if bracketDelimiter != "" {
+			if unbracketed, ok := unbracketKey(name, bracketDelimiter); ok {
+				if parsedQuery.Has(strings.TrimSuffix(key, name) + unbracketed) {
+					// delimited key takes precedence
+					continue
+				}
+				name = unbracketed
+			}
+		}
+		cf, ok := fields[name]
+		mapped := ok
+		for prefix := range valuesFields {
+			if prefix == catchAllName {
+				continue
+			}
+			if rest, ok := strings.CutPrefix(name, prefix); ok && rest != "" {
+				mapped = true
+				over, err := st.exceedsLimit(valuesFields[prefix], name, len(vals))
+				if err != nil {
+					return err
+				}
+				if over {
+					continue
+				}
+				for _, val := range vals {
+					grouped[prefix] = append(grouped[prefix], rest, val)
+				}
+			}
+		}
+		if !mapped && unknown != nil {
+			unknown(key, name, vals)
+		}
+		if catchAll, ok := valuesFields[catchAllName]; ok && !(catchAll.unmappedOnly && mapped) {
+			over, err := st.exceedsLimit(catchAll, name, len(vals))
+			if err != nil {
+				return err
+			}
+			if !over {
+				for _, val := range vals {
+					grouped[catchAllName] = append(grouped[catchAllName], name, val)
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		if err := st.set(cf, vals); err != nil {
+			return err
+		}
+	}
+
+	for prefix, vals := range grouped {
+		if err := st.set(valuesFields[prefix], vals); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unbracketKey converts bracket notation a[b][c] to a.b.c with given delimiter.
+// Keys that are not in bracket notation are reported with false.
+func unbracketKey(key, delimiter string) (string, bool) {
+	base, rest, ok := strings.Cut(key, "[")
+	if !ok || base == "" {
+		return key, false
+	}
+	var b strings.Builder
+	b.WriteString(base)
+	rest = "[" + rest
+	for rest != "" {
+		if rest[0] != '[' {
+			return key, false
+		}
+		end := strings.IndexByte(rest, ']')
+		if end <= 1 || strings.IndexByte(rest[1:end], '[') >= 0 {
+			return key, false
+		}
+		b.WriteString(delimiter)
+		b.WriteString(rest[1:end])
+		rest = rest[end+1:]
+	}
+	return b.String(), true
+}
+
+// decodeQuery picks raw query scan when it is enabled and applicable.
+func (d *Decoder) decodeQuery(r *http.Request, st *decodeState) error {
+	if d.rawQueryScan && len(d.c.queryValuesFields) == 0 && d.queryKeyPrefix.prefix == "" && !d.bracketNesting && d.unknownQuery == nil && !d.arraySuffix {
+		return unmarshalQueryScan(r, d.c.queryFields, st)
+	}
+	var bracketDelimiter string
+	if d.bracketNesting {
+		bracketDelimiter = d.c.delimiter
+	}
+	var unknown func(key, name string, vals []string)
+	if d.unknownQuery != nil {
+		unknown = func(key, name string, vals []string) {
+			if !d.c.boundQueryKey(name) {
+				d.unknownQuery(key, vals)
+			}
+		}
+	}
+	return unmarshalQuery(r, d.c.queryFields, d.c.queryValuesFields, st, d.queryKeyPrefix, bracketDelimiter, d.arraySuffix, unknown)
+}
+
+// unmarshalQueryScan walks raw query without building url.Values.
+// Scalar fields are set on every occurrence, so the last value wins,
+// slice and array fields collect all values before being set.
+// Like url.ParseQuery, keys containing semicolons and malformed escapes are skipped.
+func unmarshalQueryScan(r *http.Request, fields map[string]compiledField, st *decodeState) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	var multi map[string][]string
+	// scratch holds a single value passed to setters, which don't retain vals
+	scratch := make([]string, 1)
+	for part := range strings.SplitSeq(r.URL.RawQuery, "&") {
+		if part == "" || strings.Contains(part, ";") {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			continue
+		}
+		cf, ok := fields[key]
+		if !ok {
+			continue
 		}
-
-		if isStructExpandable(under) {
-			walkType(under, path, idx, delimiter, out)
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
 			continue
 		}
 
-		cf := compiledField{
-			idx:         idx,
-			set:         makeValueSetter(sf.Type),
-			isPtr:       isPtr,
-			structField: fmt.Sprintf("%s.%s", t.Name(), sf.Name),
+		if kind := derefType(cf.typ).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			if multi == nil {
+				multi = make(map[string][]string)
+			}
+			multi[key] = append(multi[key], value)
+			continue
 		}
-
-		fullName := strings.Join(path, delimiter)
-		switch src {
-		case tagTypeQuery:
-			out.queryFields[fullName] = cf
-		case tagTypeForm:
-			out.formFields[fullName] = cf
-		case tagTypePath:
-			out.pathFields[fullName] = cf
-		case tagTypeHeader:
-			headerName := http.CanonicalHeaderKey(fullName)
-			out.headerFields[headerName] = cf
-		case tagTypeCookie:
-			out.cookieFields[fullName] = cf
+		scratch[0] = value
+		if err := st.set(cf, scratch); err != nil {
+			return err
 		}
 	}
-}
 
-func findTag(t reflect.StructField) (string, tagType, bool) {
-	// Check for direct tag names: query, path, header, cookie
-	if tag, ok := t.Tag.Lookup("query"); ok && tag != "" {
-		return tag, tagTypeQuery, true
-	}
-	if tag, ok := t.Tag.Lookup("form"); ok && tag != "" {
-		return tag, tagTypeForm, true
-	}
-	if tag, ok := t.Tag.Lookup("path"); ok && tag != "" {
-		return tag, tagTypePath, true
-	}
-	if tag, ok := t.Tag.Lookup("header"); ok && tag != "" {
-		return tag, tagTypeHeader, true
-	}
-	if tag, ok := t.Tag.Lookup("cookie"); ok && tag != "" {
-		return tag, tagTypeCookie, true
+	for key, vals := range multi {
+		if err := st.set(fields[key], vals); err != nil {
+			return err
+		}
 	}
 
-	return "", 0, false
+	return nil
 }
 
-func isStructExpandable(t reflect.Type) bool {
-	if t.Kind() != reflect.Struct {
-		return false
+// boundQueryKey reports whether name belongs to a pair or a struct slice field,
+// which are matched outside of unmarshalQuery.
+func (c *compiledType) boundQueryKey(name string) bool {
+	for prefix := range c.queryPairFields {
+		if _, ok := cutPairKey(name, prefix, c.delimiter); ok {
+			return true
+		}
 	}
-	// Treat as scalar if it (or pointer to it) implements TextUnmarshaler.
-	if implementsTextUnmarshaler(t) || implementsTextUnmarshaler(reflect.PointerTo(t)) {
-		return false
+	if prefix, _, _, ok := parseIndexedKey(name, c.delimiter); ok {
+		_, ok := c.querySliceFields[prefix]
+		return ok
 	}
-	return true
+	return false
 }
 
-func implementsTextUnmarshaler(t reflect.Type) bool {
-	if !t.Implements(reflect.TypeFor[encoding.TextUnmarshaler]()) {
-		return false
-	}
-	return true
+// indexedValues are values of items[idx].name key of a struct slice element field.
+type indexedValues struct {
+	idx  int
+	key  string
+	sub  compiledField
+	vals []string
 }
 
-func makeValueSetter(ft reflect.Type) valueSetterFunc {
-	if ft.Kind() == reflect.Pointer {
-		elemSet := makeValueSetter(ft.Elem())
-		return func(v reflect.Value, vals []string) error {
-			if v.IsNil() {
-				v.Set(reflect.New(ft.Elem()))
-			}
-			return elemSet(v.Elem(), vals)
-		}
+// unmarshalQuerySlices sets struct slice fields from indexed keys, e.g. items[0].name=a&items[1].name=b.
+// Indices must be contiguous from 0, a gap is reported rather than left as a zero element.
+// Defaults and required modifiers of element fields apply to every element.
+func unmarshalQuerySlices(
+	r *http.Request,
+	fields map[string]compiledField,
+	delimiter string,
+	st *decodeState,
+	kp keyPrefix,
+) error {
+	if len(fields) == 0 {
+		return nil
 	}
 
-	// Slice of scalars
-	if ft.Kind() == reflect.Slice {
-		elem := ft.Elem()
-		// Slice of structs is not supported unless elem implements TextUnmarshaler.
-		if elem.Kind() == reflect.Struct && !implementsTextUnmarshaler(elem) && !implementsTextUnmarshaler(reflect.PointerTo(elem)) {
-			return func(reflect.Value, []string) error {
-				return fmt.Errorf("unsupported slice element type: %v", elem)
-			}
+	found := make(map[string][]indexedValues, len(fields))
+	for key, vals := range r.URL.Query() {
+		name, ok := kp.strip(key)
+		if !ok {
+			continue
 		}
-
-		elemSet := makeScalarSetter(elem)
-		return func(v reflect.Value, vals []string) error {
-			if len(vals) == 0 {
-				// leave zero value slice
-				return nil
-			}
-			s := reflect.MakeSlice(ft, len(vals), len(vals))
-			for i := range vals {
-				if err := elemSet(s.Index(i), vals[i]); err != nil {
-					return err
-				}
-			}
-			v.Set(s)
-			return nil
+		prefix, idx, rest, ok := parseIndexedKey(name, delimiter)
+		if !ok {
+			continue
+		}
+		cf, ok := fields[prefix]
+		if !ok {
+			continue
+		}
+		sub, ok := cf.elem.queryFields[rest]
+		if !ok {
+			continue
 		}
+		found[prefix] = append(found[prefix], indexedValues{idx: idx, key: key, sub: sub, vals: vals})
 	}
 
-	scalar := makeScalarSetter(ft)
-	return func(v reflect.Value, vals []string) error {
-		if len(vals) == 0 {
-			return nil
+	for prefix, values := range found {
+		if err := setStructSlice(fields[prefix], values, delimiter, st); err != nil {
+			return err
 		}
-		return scalar(v, vals[0])
 	}
+	return nil
 }
 
-func makeScalarSetter(ft reflect.Type) func(reflect.Value, string) error {
-	if implementsTextUnmarshaler(ft) || implementsTextUnmarshaler(reflect.PointerTo(ft)) {
-		return func(v reflect.Value, s string) error {
-			// Ensure addressable pointer receiver.
-			var tu encoding.TextUnmarshaler
-			if v.CanAddr() {
-				if x, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
-					tu = x
-				}
-			}
-			if tu == nil && v.CanInterface() {
-				if x, ok := v.Interface().(encoding.TextUnmarshaler); ok {
-					tu = x
-				}
+func setStructSlice(cf compiledField, values []indexedValues, delimiter string, st *decodeState) error {
+	seen := make(map[int]bool, len(values))
+	for _, iv := range values {
+		seen[iv.idx] = true
+	}
+	n := len(seen)
+	if over, err := st.exceedsLimit(cf, cf.name, n); over {
+		return err
+	}
+	for i := range n {
+		if !seen[i] {
+			return st.fail(st.fieldError(cf, nil, fmt.Errorf("index %d is missing", i)))
+		}
+	}
+
+	elem := cf.elem
+	s := reflect.MakeSlice(cf.typ, n, n)
+	provided := make([]bool, n*elem.fieldCount)
+	for _, iv := range values {
+		if err := iv.sub.set(fieldByIndexAlloc(s.Index(iv.idx), iv.sub.idx), iv.vals); err != nil {
+			if errors.Is(err, errValueAbsent) {
+				continue
 			}
-			if tu == nil {
-				return fmt.Errorf("type %v claims TextUnmarshaler but value not addressable", ft)
+			iv.sub.name = iv.key
+			if err := st.fail(st.fieldError(iv.sub, iv.vals, err)); err != nil {
+				return err
 			}
-			return tu.UnmarshalText([]byte(s))
+			continue
 		}
+		provided[iv.idx*elem.fieldCount+iv.sub.id] = true
 	}
 
-	switch ft.Kind() {
-	case reflect.String:
-		return func(v reflect.Value, s string) error {
-			v.SetString(s)
-			return nil
-		}
-	case reflect.Bool:
-		return func(v reflect.Value, s string) error {
-			b, err := strconv.ParseBool(s)
-			if err != nil {
-				return fmt.Errorf("parse bool: %w", err)
+	for i := range n {
+		for _, sub := range elem.defaults {
+			if provided[i*elem.fieldCount+sub.id] {
+				continue
 			}
-			v.SetBool(b)
-			return nil
-		}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		bits := ft.Bits()
-		return func(v reflect.Value, s string) error {
-			i, err := strconv.ParseInt(s, 10, bits)
-			if err != nil {
-				return fmt.Errorf("parse int: %w", err)
+			if err := sub.set(fieldByIndexAlloc(s.Index(i), sub.idx), []string{*sub.defaultValue}); err != nil {
+				return st.fail(err)
 			}
-			v.SetInt(i)
-			return nil
 		}
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
-		bits := ft.Bits()
-		return func(v reflect.Value, s string) error {
-			u, err := strconv.ParseUint(s, 10, bits)
-			if err != nil {
-				return fmt.Errorf("parse uint: %w", err)
+		for _, sub := range elem.required {
+			if provided[i*elem.fieldCount+sub.id] {
+				continue
 			}
-			v.SetUint(u)
-			return nil
-		}
-	case reflect.Float32, reflect.Float64:
-		bits := ft.Bits()
-		return func(v reflect.Value, s string) error {
-			f, err := strconv.ParseFloat(s, bits)
-			if err != nil {
-				return fmt.Errorf("parse float: %w", err)
+			sub.name = fmt.Sprintf("%s[%d]%s%s", cf.name, i, delimiter, sub.name)
+			if err := st.fail(st.fieldError(sub, nil, ErrMissingRequired)); err != nil {
+				return err
 			}
-			v.SetFloat(f)
-			return nil
-		}
-	default:
-		// Named types over the above kinds work fine with Set* calls.
-		return func(reflect.Value, string) error {
-			return fmt.Errorf("unsupported scalar type: %v", ft)
 		}
 	}
+
+	st.assign(cf, s)
+	return nil
 }
 
-func (u *Unmarshaler[T]) Unmarshal(r *http.Request, dst *T) error {
-	if u.c == nil {
-		return fmt.Errorf("Unmarshaler is not initialized")
+// parseIndexedKey splits items[0].name into items, 0 and name.
+func parseIndexedKey(key, delimiter string) (string, int, string, bool) {
+	prefix, rest, ok := strings.Cut(key, "[")
+	if !ok || prefix == "" {
+		return "", 0, "", false
 	}
-
-	if ct := r.Header.Get("Content-Type"); ct != "" {
-		if mt, _, _ := mime.ParseMediaType(ct); mt == "application/json" {
-			if err := json.NewDecoder(r.Body).Decode(dst); err != nil && !errors.Is(err, io.EOF) {
-				return err
-			}
-		}
+	digits, rest, ok := strings.Cut(rest, "]")
+	if !ok || digits == "" || strings.TrimLeft(digits, "0123456789") != "" {
+		return "", 0, "", false
 	}
-
-	// TODO: handle possible intermidiate nulls
-	// For example, target field is Struct1.Struct2.Struct3.Field
-	// and Struct2 might be null
-	root := reflect.ValueOf(dst).Elem()
-	err := firstError(
-		unmarshalQuery(r, u.c.queryFields, root),
-		unmarshalForm(r, u.c.formFields, root),
-		unmarshalPath(r, u.c.pathFields, root, u.pathLookuper),
-		unmarshalHeader(r, u.c.headerFields, root),
-		unmarshalCookie(r, u.c.cookieFields, root),
-	)
+	idx, err := strconv.Atoi(digits)
 	if err != nil {
-		return err
+		return "", 0, "", false
 	}
-
-	return nil
+	rest, ok = strings.CutPrefix(rest, delimiter)
+	if !ok || rest == "" {
+		return "", 0, "", false
+	}
+	return prefix, idx, rest, true
 }
 
-func unmarshalQuery(r *http.Request, fields map[string]compiledField, dstStruct reflect.Value) error {
+// unmarshalQueryPairs walks raw query in order, so pairs keep the order they were sent in.
+// Both prefix.key and prefix[key] forms are recognized.
+func unmarshalQueryPairs(
+	r *http.Request,
+	fields map[string]compiledField,
+	delimiter string,
+	st *decodeState,
+	kp keyPrefix,
+) error {
 	if len(fields) == 0 {
 		return nil
 	}
 
-	parsedQuery := r.URL.Query()
-
-	for key, vals := range parsedQuery {
-		cf, ok := fields[key]
+	pairs := make(map[string][]string, len(fields))
+	for part := range strings.SplitSeq(r.URL.RawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		rawKey, rawValue, _ := strings.Cut(part, "=")
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			continue
+		}
+		key, ok := kp.strip(key)
 		if !ok {
 			continue
 		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			continue
+		}
+
+		for prefix := range fields {
+			if pairKey, ok := cutPairKey(key, prefix, delimiter); ok {
+				pairs[prefix] = append(pairs[prefix], pairKey, value)
+			}
+		}
+	}
 
-		fieldV := dstStruct.FieldByIndex(cf.idx)
-		if err := cf.set(fieldV, vals); err != nil {
-			return fmt.Errorf("field %s: %w", cf.structField, err)
+	for prefix, vals := range pairs {
+		cf := fields[prefix]
+		if st.arrayLimit > 0 {
+			key, n := mostRepeatedPairKey(vals)
+			over, err := st.exceedsLimit(cf, prefix+delimiter+key, n)
+			if err != nil {
+				return err
+			}
+			if over {
+				continue
+			}
+		}
+		if err := st.set(cf, vals); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-func unmarshalForm(r *http.Request, fields map[string]compiledField, dstStruct reflect.Value) error {
+// mostRepeatedPairKey returns the key of flattened key/value pairs with most values.
+func mostRepeatedPairKey(vals []string) (string, int) {
+	counts := make(map[string]int)
+	var key string
+	var n int
+	for i := 0; i+1 < len(vals); i += 2 {
+		counts[vals[i]]++
+		if c := counts[vals[i]]; c > n {
+			key, n = vals[i], c
+		}
+	}
+	return key, n
+}
+
+func cutPairKey(key, prefix, delimiter string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, prefix)
+	if !ok {
+		return "", false
+	}
+	if pairKey, ok := strings.CutPrefix(rest, delimiter); ok && pairKey != "" {
+		return pairKey, true
+	}
+	if strings.HasPrefix(rest, "[") && strings.HasSuffix(rest, "]") && len(rest) > 2 {
+		return rest[1 : len(rest)-1], true
+	}
+	return "", false
+}
+
+// unmarshalForm parses the form, unless JSON or another body decoder has already read the body,
+// as r.ParseForm reads only urlencoded bodies. `form:"*"` field receives a copy of r.PostForm,
+// which holds values of multipart forms too.
+// Unparsed body is limited to maxBytes like in body decoders, 0 means no limit.
+func unmarshalForm(r *http.Request, fields map[string]compiledField, st *decodeState, maxBytes int64) error {
 	if len(fields) == 0 {
 		return nil
 	}
+	if maxBytes > 0 && r.PostForm == nil && r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, maxBytes)
+	}
 
 	var parseErr error
 	if ct := r.Header.Get("Content-Type"); ct != "" {
@@ -423,6 +2730,15 @@ func unmarshalForm(r *http.Request, fields map[string]compiledField, dstStruct r
 	}
 
 	for key, cf := range fields {
+		if key == catchAllName {
+			if len(r.PostForm) == 0 {
+				continue
+			}
+			if err := st.set(cf, flattenValues(r.PostForm)); err != nil {
+				return err
+			}
+			continue
+		}
 		var vals []string
 		if r.MultipartForm != nil {
 			vals = r.MultipartForm.Value[key]
@@ -434,66 +2750,201 @@ func unmarshalForm(r *http.Request, fields map[string]compiledField, dstStruct r
 			continue
 		}
 
-		fieldV := dstStruct.FieldByIndex(cf.idx)
-		if err := cf.set(fieldV, vals); err != nil {
-			return fmt.Errorf("field %s: %w", cf.structField, err)
+		if err := st.set(cf, vals); err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+// flattenValues returns key/value pairs of values, as expected by makeValuesSetter.
+func flattenValues(values url.Values) []string {
+	var flat []string
+	for key, vals := range values {
+		for _, v := range vals {
+			flat = append(flat, key, v)
+		}
+	}
+	return flat
+}
+
 func unmarshalPath(
 	r *http.Request,
 	fields map[string]compiledField,
-	dstStruct reflect.Value,
+	st *decodeState,
 	pathLookuper PathLookuperFunc,
+	pathValues PathValuesFunc,
+	requireAll bool,
 ) error {
 	if len(fields) == 0 {
 		return nil
 	}
 
+	var values map[string]string
+	if pathValues != nil {
+		values = pathValues(r)
+	}
+
 	for key, cf := range fields {
-		v, okPath := pathLookuper(r, key)
+		var v string
+		var okPath bool
+		if pathValues != nil {
+			v, okPath = values[key]
+		} else {
+			v, okPath = pathLookuper(r, key)
+		}
 		if !okPath {
+			if requireAll && !cf.fallback {
+				err := st.fieldError(cf, nil, ErrMissingRequired)
+				if err := st.fail(err); err != nil {
+					return err
+				}
+			}
 			continue
 		}
 
-		fieldV := dstStruct.FieldByIndex(cf.idx)
-		if err := cf.set(fieldV, []string{v}); err != nil {
-			return fmt.Errorf("field %s: %w", cf.structField, err)
+		if err := st.set(cf, []string{v}); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// unmarshalHeader looks up compiled fields in r.Header, which is cheaper
+// than walking all incoming headers, as structs usually want only a few of them.
+// Keys are matched case-insensitively, as r.Header may contain
+// non canonical keys, e.g. set directly or by non stdlib servers.
+// When exact is set, keys must match tag names byte for byte.
+//
+// Wildcard fields, e.g. `header:"X-Custom-*"`, collect every header with the prefix
+// into a map keyed by full header name, which is canonical unless exact is set,
+// so the map can be read like http.Header. Prefixes are canonicalized like other
+// names and matched case-insensitively, "x-custom-*" and "X-CUSTOM-*" are the same.
 func unmarshalHeader(
 	r *http.Request,
 	fields map[string]compiledField,
-	dstStruct reflect.Value,
+	valuesFields map[string]compiledField,
+	st *decodeState,
+	exact bool,
+	merge bool,
 ) error {
-	if len(fields) == 0 {
+	if len(fields) == 0 && len(valuesFields) == 0 {
 		return nil
 	}
 
-	for key, vals := range r.Header {
-		cf, ok := fields[key]
+	header := r.Header
+	if !exact && merge {
+		header = canonicalHeader(header)
+	}
+	// folded indexes non canonical keys on the first miss, it stays nil when all keys are canonical
+	var folded http.Header
+	foldedBuilt := false
+	for name, cf := range fields {
+		vals, ok := header[name]
+		if !ok && !exact && !merge {
+			if !foldedBuilt {
+				folded, foldedBuilt = foldHeader(header), true
+			}
+			vals, ok = folded[name]
+		}
 		if !ok {
 			continue
 		}
 
-		fieldV := dstStruct.FieldByIndex(cf.idx)
-		if err := cf.set(fieldV, vals); err != nil {
-			return fmt.Errorf("field %s: %w", cf.structField, err)
+		if err := st.set(cf, vals); err != nil {
+			return err
+		}
+	}
+
+	if len(valuesFields) == 0 {
+		return nil
+	}
+	grouped := make(map[string][]string, len(valuesFields))
+	for key, vals := range header {
+		if !exact {
+			key = http.CanonicalHeaderKey(key)
+		}
+		for name := range valuesFields {
+			if !strings.HasPrefix(key, strings.TrimSuffix(name, catchAllName)) {
+				continue
+			}
+			over, err := st.exceedsLimit(valuesFields[name], key, len(vals))
+			if err != nil {
+				return err
+			}
+			if over {
+				continue
+			}
+			for _, val := range vals {
+				grouped[name] = append(grouped[name], key, val)
+			}
+		}
+	}
+	for name, vals := range grouped {
+		if err := st.set(valuesFields[name], vals); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// foldHeader returns values of non canonical keys of h by their canonical form,
+// so fields can look them up without scanning h. Of keys differing only in case
+// the first one in sorted order wins. nil is returned when all keys are canonical.
+func foldHeader(h http.Header) http.Header {
+	var variants []string
+	for key := range h {
+		if http.CanonicalHeaderKey(key) != key {
+			variants = append(variants, key)
+		}
+	}
+	if len(variants) == 0 {
+		return nil
+	}
+	slices.Sort(variants)
+	folded := make(http.Header, len(variants))
+	for _, key := range variants {
+		canonical := http.CanonicalHeaderKey(key)
+		if _, ok := folded[canonical]; !ok {
+			folded[canonical] = h[key]
+		}
+	}
+	return folded
+}
+
+// canonicalHeader returns h with canonical keys for WithHeaderCaseMerging.
+// Values of keys differing only in case are merged: canonical key first, then the others sorted.
+// h is returned as is when all its keys are canonical, which is the common case.
+func canonicalHeader(h http.Header) http.Header {
+	var variants []string
+	for key := range h {
+		if http.CanonicalHeaderKey(key) != key {
+			variants = append(variants, key)
+		}
+	}
+	if len(variants) == 0 {
+		return h
+	}
+	slices.Sort(variants)
+	out := make(http.Header, len(h))
+	for key, vals := range h {
+		if http.CanonicalHeaderKey(key) == key {
+			out[key] = vals
+		}
+	}
+	for _, key := range variants {
+		canonical := http.CanonicalHeaderKey(key)
+		// clip so values of h aren't overwritten
+		out[canonical] = append(slices.Clip(out[canonical]), h[key]...)
+	}
+	return out
+}
+
 func unmarshalCookie(
 	r *http.Request,
 	fields map[string]compiledField,
-	dstStruct reflect.Value,
+	st *decodeState,
 ) error {
 	if len(fields) == 0 {
 		return nil
@@ -501,24 +2952,18 @@ func unmarshalCookie(
 
 	for key, cf := range fields {
 		c, err := r.Cookie(key)
+		if errors.Is(err, http.ErrNoCookie) {
+			// absent, so the next source of a from tag can be tried
+			continue
+		}
 		if err != nil {
 			return fmt.Errorf("cookie %s is invalid: %w", key, err)
 		}
 
-		fieldV := dstStruct.FieldByIndex(cf.idx)
-		if err := cf.set(fieldV, []string{c.Value}); err != nil {
-			return fmt.Errorf("field %s: %w", cf.structField, err)
-		}
-	}
-
-	return nil
-}
-
-func firstError(errs ...error) error {
-	for _, err := range errs {
-		if err != nil {
+		if err := st.set(cf, []string{c.Value}); err != nil {
 			return err
 		}
 	}
+
 	return nil
 }
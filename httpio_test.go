@@ -2,12 +2,28 @@ package httpio_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/pechorka/httpio"
 )
@@ -213,6 +229,4023 @@ func TestUnmarshal(t *testing.T) {
 		assertEqual(t, "http", v.Tags[1])
 		assertEqual(t, true, v.Publish)
 	})
+
+	t.Run("xml body and header params", func(t *testing.T) {
+		type input struct {
+			RequestID string `header:"X-Request-ID"`
+			Name      string `xml:"name"`
+			Amount    int    `xml:"amount"`
+		}
+
+		body := `<order><name>widget</name><amount>3</amount></order>`
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/xml; charset=utf-8")
+		r.Header.Set("X-Request-ID", "req-1")
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "req-1", v.RequestID)
+		assertEqual(t, "widget", v.Name)
+		assertEqual(t, 3, v.Amount)
+	})
+
+	t.Run("empty xml body", func(t *testing.T) {
+		type input struct {
+			Name string `xml:"name"`
+		}
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader(""))
+		r.Header.Set("Content-Type", "text/xml")
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "", v.Name)
+	})
+
+	t.Run("custom body decoder", func(t *testing.T) {
+		type input struct {
+			Raw string
+		}
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+		r.Header.Set("Content-Type", "text/plain")
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](
+			httpio.WithBodyDecoder("text/plain", func(body io.Reader, dst any) error {
+				b, err := io.ReadAll(body)
+				if err != nil {
+					return err
+				}
+				dst.(*input).Raw = string(b)
+				return nil
+			}),
+		)
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "hello", v.Raw)
+	})
+
+	t.Run("netip params", func(t *testing.T) {
+		type input struct {
+			Network  netip.Prefix    `query:"network"`
+			Endpoint *netip.AddrPort `query:"endpoint"`
+			Allowed  []netip.Prefix  `query:"allowed"`
+			Missing  *netip.Prefix   `query:"missing"`
+		}
+
+		r := httptest.NewRequest("GET", "/?network=10.0.0.0/8&endpoint=127.0.0.1:8080&allowed=192.168.0.0/16&allowed=fd00::/8", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, netip.MustParsePrefix("10.0.0.0/8"), v.Network)
+		assertEqual(t, netip.MustParseAddrPort("127.0.0.1:8080"), *v.Endpoint)
+		assertEqual(t, 2, len(v.Allowed))
+		assertEqual(t, netip.MustParsePrefix("192.168.0.0/16"), v.Allowed[0])
+		assertEqual(t, netip.MustParsePrefix("fd00::/8"), v.Allowed[1])
+		assertEqual(t, (*netip.Prefix)(nil), v.Missing)
+	})
+
+	t.Run("invalid netip param", func(t *testing.T) {
+		type input struct {
+			Network netip.Prefix `query:"network"`
+		}
+
+		r := httptest.NewRequest("GET", "/?network=not-a-prefix", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+	})
+
+	t.Run("ordered pairs from query", func(t *testing.T) {
+		type pair struct {
+			Key   string
+			Value string
+		}
+		type input struct {
+			Filters []pair `query:"filter"`
+			Sort    []pair `query:"sort"`
+			Page    int    `query:"page"`
+		}
+
+		r := httptest.NewRequest("GET", "/?filter.zeta=1&page=2&filter[alpha]=2&sort.name=asc&filter.mid=3&filter.zeta=4", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, 4, len(v.Filters))
+		assertEqual(t, pair{"zeta", "1"}, v.Filters[0])
+		assertEqual(t, pair{"alpha", "2"}, v.Filters[1])
+		assertEqual(t, pair{"mid", "3"}, v.Filters[2])
+		assertEqual(t, pair{"zeta", "4"}, v.Filters[3])
+		assertEqual(t, 1, len(v.Sort))
+		assertEqual(t, pair{"name", "asc"}, v.Sort[0])
+		assertEqual(t, 2, v.Page)
+	})
+
+	t.Run("multi-character delimiter", func(t *testing.T) {
+		type fullName struct {
+			First string `query:"first"`
+			Last  string `query:"last"`
+		}
+		type input struct {
+			Name fullName `query:"name"`
+		}
+
+		r := httptest.NewRequest("GET", "/?name__first=John&name__last=Doe&name.first=Wrong", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithDelimiter("__"))
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "John", v.Name.First)
+		assertEqual(t, "Doe", v.Name.Last)
+
+		// same type with default delimiter must not reuse the "__" compilation
+		dotUnmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var dotV input
+		err = dotUnmarshaler.Unmarshal(r, &dotV)
+		assertNoError(t, err)
+
+		assertEqual(t, "Wrong", dotV.Name.First)
+	})
+
+	t.Run("empty delimiter", func(t *testing.T) {
+		type input struct {
+			Name string `query:"name"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input](httpio.WithDelimiter(""))
+		assertError(t, err)
+	})
+
+	t.Run("integer overflow", func(t *testing.T) {
+		type level int8
+		type input struct {
+			Small  int8   `query:"small"`
+			Byte   uint8  `query:"byte"`
+			Medium int32  `query:"medium"`
+			Level  level  `query:"level"`
+			Count  uint16 `query:"count"`
+		}
+
+		tests := []struct {
+			query string
+			field string
+			typ   string
+		}{
+			{query: "small=128", field: "input.Small", typ: "int8"},
+			{query: "byte=256", field: "input.Byte", typ: "uint8"},
+			{query: "medium=2147483648", field: "input.Medium", typ: "int32"},
+			{query: "level=-129", field: "input.Level", typ: "httpio_test.level"},
+			{query: "count=70000", field: "input.Count", typ: "uint16"},
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		for _, tt := range tests {
+			r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+
+			var v input
+			err = unmarshaler.Unmarshal(r, &v)
+			assertError(t, err)
+			if !errors.Is(err, strconv.ErrRange) {
+				t.Fatalf("expected range error, got %v", err)
+			}
+			assertContains(t, err.Error(), tt.field)
+			assertContains(t, err.Error(), "out of range for type "+tt.typ)
+		}
+	})
+
+	t.Run("incoming query key prefix", func(t *testing.T) {
+		type input struct {
+			Page  int `query:"page"`
+			Limit int `query:"limit"`
+		}
+
+		r := httptest.NewRequest("GET", "/?q_page=2&limit=50&q_limit=10", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithIncomingKeyPrefix("q_", false))
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 2, v.Page)
+		assertEqual(t, 10, v.Limit)
+
+		r = httptest.NewRequest("GET", "/?q_page=2&limit=50", nil)
+
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 2, v.Page)
+		assertEqual(t, 0, v.Limit)
+	})
+
+	t.Run("incoming query key prefix with unprefixed keys", func(t *testing.T) {
+		type input struct {
+			Page  int `query:"page"`
+			Limit int `query:"limit"`
+		}
+
+		r := httptest.NewRequest("GET", "/?q_page=2&page=3&limit=50", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithIncomingKeyPrefix("q_", true))
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 2, v.Page)
+		assertEqual(t, 50, v.Limit)
+	})
+
+	t.Run("media type injection", func(t *testing.T) {
+		type input struct {
+			MediaType   string            `inject:"media_type"`
+			MediaParams map[string]string `inject:"media_params"`
+			Name        string            `json:"name"`
+		}
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"test"}`))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8; version=2")
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "application/json", v.MediaType)
+		assertEqual(t, 2, len(v.MediaParams))
+		assertEqual(t, "utf-8", v.MediaParams["charset"])
+		assertEqual(t, "2", v.MediaParams["version"])
+		assertEqual(t, "test", v.Name)
+	})
+
+	t.Run("media type injection without content type", func(t *testing.T) {
+		type input struct {
+			MediaType *string `inject:"media_type"`
+		}
+
+		r := httptest.NewRequest("GET", "/", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, (*string)(nil), v.MediaType)
+	})
+
+	t.Run("invalid inject fields", func(t *testing.T) {
+		type unknownName struct {
+			Value string `inject:"unknown"`
+		}
+		_, err := httpio.NewUnmarshaler[unknownName]()
+		assertError(t, err)
+
+		type wrongType struct {
+			MediaType int `inject:"media_type"`
+		}
+		_, err = httpio.NewUnmarshaler[wrongType]()
+		assertError(t, err)
+	})
+
+	t.Run("embedded struct promotion", func(t *testing.T) {
+		type Pagination struct {
+			Limit  int `query:"limit"`
+			Offset int `query:"offset"`
+		}
+		type sorting struct {
+			Sort string `query:"sort"`
+		}
+		type input struct {
+			Pagination
+			sorting
+			Search string `query:"search"`
+		}
+
+		r := httptest.NewRequest("GET", "/?limit=10&offset=20&sort=name&search=go&Pagination.limit=99", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, 10, v.Limit)
+		assertEqual(t, 20, v.Offset)
+		assertEqual(t, "name", v.Sort)
+		assertEqual(t, "go", v.Search)
+	})
+
+	t.Run("oneof modifier", func(t *testing.T) {
+		type input struct {
+			Sort     string `query:"sort,oneof=asc desc,default=asc"`
+			Priority *int   `query:"priority,oneof=1 2 3"`
+			Status   string `query:"status,required,oneof=open closed"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?priority=02&status=open", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "asc", v.Sort)
+		assertEqual(t, 2, *v.Priority)
+		assertEqual(t, "open", v.Status)
+
+		r = httptest.NewRequest("GET", "/?sort=random&status=open", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "[asc desc]")
+
+		r = httptest.NewRequest("GET", "/?priority=4&status=open", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "[1 2 3]")
+
+		r = httptest.NewRequest("GET", "/?sort=desc", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+	})
+
+	t.Run("default modifier", func(t *testing.T) {
+		type input struct {
+			Limit  int      `query:"limit,default=20"`
+			Offset *int     `query:"offset,default=0"`
+			Lang   string   `header:"Accept-Language,default=en"`
+			Tags   []string `query:"tags,default=all"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?limit=5", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 5, v.Limit)
+		assertEqual(t, 0, *v.Offset)
+		assertEqual(t, "en", v.Lang)
+		assertEqual(t, 1, len(v.Tags))
+		assertEqual(t, "all", v.Tags[0])
+	})
+
+	t.Run("invalid oneof and default modifiers", func(t *testing.T) {
+		type badOneOf struct {
+			Priority int `query:"priority,oneof=1 two"`
+		}
+		_, err := httpio.NewUnmarshaler[badOneOf]()
+		assertError(t, err)
+
+		type badDefault struct {
+			Sort string `query:"sort,oneof=asc desc,default=random"`
+		}
+		_, err = httpio.NewUnmarshaler[badDefault]()
+		assertError(t, err)
+
+		type requiredDefault struct {
+			Sort string `query:"sort,required,default=asc"`
+		}
+		_, err = httpio.NewUnmarshaler[requiredDefault]()
+		assertError(t, err)
+	})
+
+	t.Run("form and query params with the same name", func(t *testing.T) {
+		type input struct {
+			QueryTags []string `query:"tags"`
+			FormTags  []string `form:"tags"`
+		}
+
+		form := url.Values{}
+		form.Add("tags", "form-a")
+		form.Add("tags", "form-b")
+		form.Add("tags", "form-c")
+
+		r := httptest.NewRequest("POST", "/?tags=query-a&tags=query-b", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "query-a query-b", strings.Join(v.QueryTags, " "))
+		assertEqual(t, "form-a form-b form-c", strings.Join(v.FormTags, " "))
+	})
+
+	t.Run("meta params", func(t *testing.T) {
+		type input struct {
+			RemoteAddr netip.AddrPort `meta:"remote_addr"`
+			Method     string         `meta:"method"`
+			Path       string         `meta:"path"`
+			TLS        bool           `meta:"tls"`
+		}
+
+		r := httptest.NewRequest("PUT", "https://example.com/users/1?x=1", nil)
+		r.RemoteAddr = "10.0.0.1:5555"
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, netip.MustParseAddrPort("10.0.0.1:5555"), v.RemoteAddr)
+		assertEqual(t, "PUT", v.Method)
+		assertEqual(t, "/users/1", v.Path)
+		assertEqual(t, true, v.TLS)
+	})
+
+	t.Run("unknown meta key", func(t *testing.T) {
+		type input struct {
+			Value string `meta:"unknown"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err)
+	})
+
+	t.Run("truevalue modifier", func(t *testing.T) {
+		type input struct {
+			Strict bool  `query:"mode,truevalue=strict"`
+			Debug  *bool `query:"debug,truevalue=on"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		tests := []struct {
+			query  string
+			strict bool
+		}{
+			{query: "mode=strict", strict: true},
+			{query: "mode=lenient", strict: false},
+			{query: "mode=true", strict: false},
+			{query: "", strict: false},
+		}
+		for _, tt := range tests {
+			r := httptest.NewRequest("GET", "/?"+tt.query, nil)
+
+			var v input
+			err = unmarshaler.Unmarshal(r, &v)
+			assertNoError(t, err)
+			assertEqual(t, tt.strict, v.Strict)
+			assertEqual(t, (*bool)(nil), v.Debug)
+		}
+
+		r := httptest.NewRequest("GET", "/?debug=off", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, false, *v.Debug)
+	})
+
+	t.Run("truevalue modifier on non-bool field", func(t *testing.T) {
+		type input struct {
+			Mode string `query:"mode,truevalue=strict"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err)
+	})
+
+	t.Run("fixed size arrays", func(t *testing.T) {
+		type input struct {
+			Coords [2]float64 `query:"coords"`
+			RGB    *[3]uint8  `query:"rgb,csv"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?coords=1.5&coords=2.5&rgb=255,128,0", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, [2]float64{1.5, 2.5}, v.Coords)
+		assertEqual(t, [3]uint8{255, 128, 0}, *v.RGB)
+
+		r = httptest.NewRequest("GET", "/?coords=1.5", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "expected 2 values, got 1")
+
+		r = httptest.NewRequest("GET", "/?coords=1.5&coords=2.5&coords=3.5", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "expected 2 values, got 3")
+	})
+
+	t.Run("raw modifier", func(t *testing.T) {
+		type input struct {
+			Payload json.RawMessage  `query:"payload,raw"`
+			Data    []byte           `query:"data,raw"`
+			Extra   *json.RawMessage `query:"extra,raw"`
+		}
+
+		payload := `{"id":1,"tags":["a","b"]}`
+		r := httptest.NewRequest("GET", "/?payload="+url.QueryEscape(payload)+"&data=hello", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, payload, string(v.Payload))
+		assertEqual(t, "hello", string(v.Data))
+		assertEqual(t, (*json.RawMessage)(nil), v.Extra)
+	})
+
+	t.Run("raw modifier on non-bytes field", func(t *testing.T) {
+		type input struct {
+			Payload string `query:"payload,raw"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err)
+	})
+
+	t.Run("per call required fields", func(t *testing.T) {
+		type input struct {
+			ID    string `path:"id"`
+			Name  string `query:"name,required"`
+			Email string `query:"email"`
+			Token string `header:"X-Token"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		// create: name and email are required
+		r := httptest.NewRequest("POST", "/?name=john", nil)
+		var v input
+		err = unmarshaler.UnmarshalRequired(r, &v, "name", "email")
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertContains(t, err.Error(), `"email"`)
+
+		// update: only id is required, tag level required name is overridden
+		r = httptest.NewRequest("PATCH", "/", nil)
+		r.SetPathValue("id", "1")
+		r.Header.Set("X-Token", "secret")
+		v = input{}
+		err = unmarshaler.UnmarshalRequired(r, &v, "id", "x-token")
+		assertNoError(t, err)
+		assertEqual(t, "1", v.ID)
+
+		r = httptest.NewRequest("PATCH", "/", nil)
+		v = input{}
+		err = unmarshaler.UnmarshalRequired(r, &v, "id")
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+
+		err = unmarshaler.UnmarshalRequired(r, &v, "unknown")
+		assertError(t, err)
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		type input struct {
+			Age    int    `query:"age"`
+			Name   string `query:"name"`
+			Count  uint   `query:"count"`
+			Token  string `header:"X-Token,required"`
+			Active bool   `header:"X-Active"`
+		}
+
+		r := httptest.NewRequest("GET", "/?age=abc&name=john&count=-1", nil)
+		r.Header.Set("X-Active", "true")
+
+		strict, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = strict.Unmarshal(r, &v)
+		assertError(t, err)
+
+		bestEffort, err := httpio.NewUnmarshaler[input](httpio.WithBestEffort())
+		assertNoError(t, err)
+
+		v = input{}
+		err = bestEffort.Unmarshal(r, &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "input.Age")
+		assertContains(t, err.Error(), "input.Count")
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+
+		assertEqual(t, "john", v.Name)
+		assertEqual(t, true, v.Active)
+	})
+
+	t.Run("named and anonymous embedding prefixes", func(t *testing.T) {
+		type Pagination struct {
+			Page int `query:"page"`
+			Size int `query:"size"`
+		}
+		type named struct {
+			Pg Pagination `query:"pg"`
+		}
+		type taggedEmbedded struct {
+			Pagination `query:"pg"`
+		}
+		type untaggedEmbedded struct {
+			Pagination
+		}
+
+		r := httptest.NewRequest("GET", "/?pg.page=2&pg.size=50&page=3&size=10", nil)
+
+		namedUnmarshaler, err := httpio.NewUnmarshaler[named]()
+		assertNoError(t, err)
+		var n named
+		err = namedUnmarshaler.Unmarshal(r, &n)
+		assertNoError(t, err)
+		assertEqual(t, Pagination{Page: 2, Size: 50}, n.Pg)
+
+		taggedUnmarshaler, err := httpio.NewUnmarshaler[taggedEmbedded]()
+		assertNoError(t, err)
+		var te taggedEmbedded
+		err = taggedUnmarshaler.Unmarshal(r, &te)
+		assertNoError(t, err)
+		assertEqual(t, Pagination{Page: 2, Size: 50}, te.Pagination)
+
+		untaggedUnmarshaler, err := httpio.NewUnmarshaler[untaggedEmbedded]()
+		assertNoError(t, err)
+		var ue untaggedEmbedded
+		err = untaggedUnmarshaler.Unmarshal(r, &ue)
+		assertNoError(t, err)
+		assertEqual(t, Pagination{Page: 3, Size: 10}, ue.Pagination)
+	})
+
+	t.Run("path values", func(t *testing.T) {
+		type input struct {
+			ID   int     `path:"id"`
+			Slug *string `path:"slug"`
+			Tab  string  `path:"tab,default=overview"`
+		}
+
+		calls := 0
+		vars := func(r *http.Request) map[string]string {
+			calls++
+			return map[string]string{"id": "42"}
+		}
+		lookuper := func(r *http.Request, name string) (string, bool) {
+			if name == "id" {
+				return "7", true
+			}
+			return "", false
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithPathValues(vars))
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, 42, v.ID)
+		assertEqual(t, (*string)(nil), v.Slug)
+		assertEqual(t, "overview", v.Tab)
+		assertEqual(t, 1, calls)
+
+		valuesLast, err := httpio.NewUnmarshaler[input](httpio.WithPathLookuper(lookuper), httpio.WithPathValues(vars))
+		assertNoError(t, err)
+		v = input{}
+		err = valuesLast.Unmarshal(httptest.NewRequest("GET", "/", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, 42, v.ID)
+
+		lookuperLast, err := httpio.NewUnmarshaler[input](httpio.WithPathValues(vars), httpio.WithPathLookuper(lookuper))
+		assertNoError(t, err)
+		v = input{}
+		err = lookuperLast.Unmarshal(httptest.NewRequest("GET", "/", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, 7, v.ID)
+
+		type required struct {
+			Slug string `path:"slug,required"`
+		}
+		requiredUnmarshaler, err := httpio.NewUnmarshaler[required](httpio.WithPathValues(vars))
+		assertNoError(t, err)
+		var rv required
+		err = requiredUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/", nil), &rv)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertContains(t, err.Error(), `path parameter "slug"`)
+	})
+
+	t.Run("protocol version injection", func(t *testing.T) {
+		type input struct {
+			ProtoMajor int  `inject:"proto_major"`
+			IsHTTP2    bool `inject:"is_http2"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		tests := []struct {
+			major   int
+			minor   int
+			isHTTP2 bool
+		}{
+			{major: 1, minor: 1, isHTTP2: false},
+			{major: 2, minor: 0, isHTTP2: true},
+			{major: 3, minor: 0, isHTTP2: false},
+		}
+		for _, tt := range tests {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.ProtoMajor, r.ProtoMinor = tt.major, tt.minor
+
+			var v input
+			err = unmarshaler.Unmarshal(r, &v)
+			assertNoError(t, err)
+			assertEqual(t, tt.major, v.ProtoMajor)
+			assertEqual(t, tt.isHTTP2, v.IsHTTP2)
+		}
+
+		type wrongType struct {
+			IsHTTP2 string `inject:"is_http2"`
+		}
+		_, err = httpio.NewUnmarshaler[wrongType]()
+		assertError(t, err)
+	})
+
+	t.Run("router path param adapters", func(t *testing.T) {
+		type input struct {
+			UserID string `path:"user_id"`
+			OrgID  string `path:"org_id"`
+		}
+
+		// mimics chi.URLParam
+		urlParam := func(r *http.Request, name string) string {
+			if name == "user_id" {
+				return "42"
+			}
+			return ""
+		}
+		// mimics mux.Vars
+		vars := func(r *http.Request) map[string]string {
+			return map[string]string{"user_id": "43"}
+		}
+
+		chiLike, err := httpio.NewUnmarshaler[input](httpio.WithPathLookuper(httpio.PathParamLookuper(urlParam)))
+		assertNoError(t, err)
+		muxLike, err := httpio.NewUnmarshaler[input](httpio.WithPathValues(vars))
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/", nil)
+
+		v := input{OrgID: "unchanged"}
+		err = chiLike.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "42", v.UserID)
+		assertEqual(t, "unchanged", v.OrgID)
+
+		v = input{OrgID: "unchanged"}
+		err = muxLike.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "43", v.UserID)
+		assertEqual(t, "unchanged", v.OrgID)
+	})
+
+	t.Run("repeated headers", func(t *testing.T) {
+		type input struct {
+			Forwarded []string `header:"X-Forwarded-Host"`
+			Trace     string   `header:"X-Trace"`
+			Retries   []int    `header:"X-Retry"`
+		}
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Add("X-Forwarded-Host", "a.example.com")
+		r.Header.Add("X-Forwarded-Host", "b.example.com")
+		r.Header.Add("X-Trace", "first")
+		r.Header.Add("X-Trace", "second")
+		r.Header.Add("X-Retry", "1")
+		r.Header.Add("X-Retry", "2")
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "a.example.com b.example.com", strings.Join(v.Forwarded, " "))
+		// scalar fields take the first header line
+		assertEqual(t, "first", v.Trace)
+		assertEqual(t, 2, len(v.Retries))
+		assertEqual(t, 2, v.Retries[1])
+	})
+
+	t.Run("in tag style", func(t *testing.T) {
+		type fullName struct {
+			First string `in:"query=first"`
+			Last  string `query:"last"`
+		}
+		type input struct {
+			Name    fullName `in:"query=name"`
+			Age     int      `in:"query=age"`
+			UserID  string   `in:"path=user_id"`
+			Token   string   `in:"header=X-Token"`
+			Session string   `in:"cookie=session"`
+		}
+
+		r := httptest.NewRequest("GET", "/?name.first=John&name.last=Doe&age=30", nil)
+		r.SetPathValue("user_id", "u1")
+		r.Header.Set("X-Token", "secret")
+		r.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "John", v.Name.First)
+		assertEqual(t, "Doe", v.Name.Last)
+		assertEqual(t, 30, v.Age)
+		assertEqual(t, "u1", v.UserID)
+		assertEqual(t, "secret", v.Token)
+		assertEqual(t, "s1", v.Session)
+	})
+
+	t.Run("invalid in tags", func(t *testing.T) {
+		type unknownSource struct {
+			Age int `in:"body=age"`
+		}
+		_, err := httpio.NewUnmarshaler[unknownSource]()
+		assertError(t, err)
+
+		type missingName struct {
+			Age int `in:"query"`
+		}
+		_, err = httpio.NewUnmarshaler[missingName]()
+		assertError(t, err)
+	})
+
+	t.Run("deadline injection", func(t *testing.T) {
+		type input struct {
+			Deadline *time.Time `inject:"deadline"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		deadline := time.Now().Add(time.Minute)
+		ctx, cancel := context.WithDeadline(context.Background(), deadline)
+		defer cancel()
+		r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, true, deadline.Equal(*v.Deadline))
+
+		r = httptest.NewRequest("GET", "/", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, (*time.Time)(nil), v.Deadline)
+	})
+
+	t.Run("comma separated slices", func(t *testing.T) {
+		type input struct {
+			Tags   []string `query:"tags,csv"`
+			IDs    []int    `query:"ids,csv"`
+			Parts  []string `query:"parts,sep=|"`
+			Empty  []string `query:"empty,csv"`
+			Single []string `query:"single"`
+		}
+
+		r := httptest.NewRequest("GET", "/?tags=a,b,c&tags=d&ids=1,2&parts=x|y&empty=a,,b&single=a,b", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "a b c d", strings.Join(v.Tags, " "))
+		assertEqual(t, 2, len(v.IDs))
+		assertEqual(t, 1, v.IDs[0])
+		assertEqual(t, 2, v.IDs[1])
+		assertEqual(t, "x y", strings.Join(v.Parts, " "))
+		assertEqual(t, 3, len(v.Empty))
+		assertEqual(t, "", v.Empty[1])
+		assertEqual(t, 1, len(v.Single))
+		assertEqual(t, "a,b", v.Single[0])
+	})
+
+	t.Run("comma separated slice with empty int element", func(t *testing.T) {
+		type input struct {
+			IDs []int `query:"ids,csv"`
+		}
+
+		r := httptest.NewRequest("GET", "/?ids=1,,2", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+	})
+
+	t.Run("invalid tag modifiers", func(t *testing.T) {
+		type unknownModifier struct {
+			Tags []string `query:"tags,bogus"`
+		}
+		_, err := httpio.NewUnmarshaler[unknownModifier]()
+		assertError(t, err)
+
+		type csvScalar struct {
+			Tag string `query:"tag,csv"`
+		}
+		_, err = httpio.NewUnmarshaler[csvScalar]()
+		assertError(t, err)
+	})
+
+	t.Run("combined slice constraints", func(t *testing.T) {
+		type input struct {
+			Statuses []string `query:"status,csv,oneof=open closed pending,unique,max=2"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?status=open,closed,open&status=closed", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "open closed", strings.Join(v.Statuses, " "))
+
+		r = httptest.NewRequest("GET", "/?status=closed,open,closed", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "closed open", strings.Join(v.Statuses, " "))
+
+		r = httptest.NewRequest("GET", "/?status=open,archived", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "[open closed pending]")
+
+		r = httptest.NewRequest("GET", "/?status=open,closed,pending", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "at most 2 values, got 3")
+	})
+
+	t.Run("slice constraints on scalar field", func(t *testing.T) {
+		type input struct {
+			Status string `query:"status,unique"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err)
+	})
+
+	t.Run("in tag modifiers", func(t *testing.T) {
+		type input struct {
+			Age   int      `in:"query=age;required"`
+			Limit int      `in:"query=limit;default=20"`
+			Tags  []string `in:"query=tags;csv;default=a,b"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?age=30", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 30, v.Age)
+		assertEqual(t, 20, v.Limit)
+		assertEqual(t, "a b", strings.Join(v.Tags, " "))
+
+		r = httptest.NewRequest("GET", "/?limit=5", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+	})
+
+	t.Run("after bind hook", func(t *testing.T) {
+		type input struct {
+			ID int `query:"id"`
+		}
+
+		calls := 0
+		hookErr := errors.New("hook failed")
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithAfterBind(func(v *input, r *http.Request) error {
+			calls++
+			if v.ID == 13 {
+				return hookErr
+			}
+			v.ID *= 2
+			return nil
+		}))
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?id=21", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, 42, v.ID)
+		assertEqual(t, 1, calls)
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?id=abc", nil), &v)
+		assertError(t, err)
+		assertEqual(t, 1, calls)
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?id=13", nil), &v)
+		if !errors.Is(err, hookErr) {
+			t.Fatalf("expected hook error, got %v", err)
+		}
+		assertEqual(t, 2, calls)
+	})
+
+	t.Run("after bind hook type mismatch", func(t *testing.T) {
+		type input struct {
+			ID int `query:"id"`
+		}
+		type other struct{}
+
+		_, err := httpio.NewUnmarshaler[input](httpio.WithAfterBind(func(*other, *http.Request) error { return nil }))
+		assertError(t, err)
+	})
+
+	t.Run("buffered body", func(t *testing.T) {
+		type input struct {
+			Name string `json:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithBufferedBody())
+		assertNoError(t, err)
+
+		const body = `{"name":"john"}`
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "john", v.Name)
+
+		rest, err := io.ReadAll(r.Body)
+		assertNoError(t, err)
+		assertEqual(t, body, string(rest))
+	})
+
+	t.Run("without body", func(t *testing.T) {
+		type input struct {
+			Name string `json:"name"`
+			ID   int    `query:"id"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithoutBody())
+		assertNoError(t, err)
+
+		const body = `{"name":"john"}`
+		r := httptest.NewRequest("POST", "/?id=1", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "", v.Name)
+		assertEqual(t, 1, v.ID)
+
+		rest, err := io.ReadAll(r.Body)
+		assertNoError(t, err)
+		assertEqual(t, body, string(rest))
+	})
+
+	t.Run("url.Values by prefix", func(t *testing.T) {
+		type input struct {
+			ID          int        `query:"id"`
+			Passthrough url.Values `query:"passthrough."`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?id=1&passthrough.tag=a&passthrough.tag=b&passthrough.page=2&other=x", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.ID)
+		assertEqual(t, "tag=a&tag=b", url.Values{"tag": v.Passthrough["tag"]}.Encode())
+		assertEqual(t, "2", v.Passthrough.Get("page"))
+		assertEqual(t, 2, len(v.Passthrough))
+
+		r = httptest.NewRequest("GET", "/?id=1", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		if v.Passthrough != nil {
+			t.Fatalf("expected nil values, got %v", v.Passthrough)
+		}
+	})
+
+	t.Run("json number and raw message", func(t *testing.T) {
+		type input struct {
+			ID      json.Number     `query:"id"`
+			Payload json.RawMessage `query:"payload"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		q := url.Values{"id": {"900719925474099199"}, "payload": {`{"a":[1,2]}`}}
+		r := httptest.NewRequest("GET", "/?"+q.Encode(), nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, json.Number("900719925474099199"), v.ID)
+		id, err := v.ID.Int64()
+		assertNoError(t, err)
+		assertEqual(t, int64(900719925474099199), id)
+		assertEqual(t, `{"a":[1,2]}`, string(v.Payload))
+
+		r = httptest.NewRequest("GET", "/?id=12abc", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+	})
+
+	t.Run("header equals", func(t *testing.T) {
+		type input struct {
+			Beta bool `header:"X-Feature,equals=beta"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		for _, tc := range []struct {
+			header string
+			want   bool
+		}{
+			{header: "beta", want: true},
+			{header: "alpha", want: false},
+			{header: "", want: false},
+		} {
+			r := httptest.NewRequest("GET", "/", nil)
+			var v input
+			if tc.header != "" {
+				r.Header.Set("X-Feature", tc.header)
+				// non-matching value must reset the field
+				v.Beta = !tc.want
+			}
+			err = unmarshaler.Unmarshal(r, &v)
+			assertNoError(t, err)
+			assertEqual(t, tc.want, v.Beta)
+		}
+
+		type badInput struct {
+			Feature string `header:"X-Feature,equals=beta"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("all compile errors reported at once", func(t *testing.T) {
+		type item struct {
+			Name string
+		}
+		type input struct {
+			Items    []map[string]int `query:"items"`
+			Callback func()           `query:"callback"`
+			Age      int              `query:"age,bogus"`
+			Token    string           `query:"token" ctx:"token"`
+			Body     []item           `json:"body"`
+			Ch       chan int         `json:"ch"`
+			Meta     map[string]int   `json:"meta"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "input.Items: unsupported type: slice element")
+		assertContains(t, err.Error(), "input.Callback: unsupported type")
+		assertContains(t, err.Error(), `input.Age: unknown tag modifier "bogus"`)
+		assertContains(t, err.Error(), "input.Token: conflicting source tags: query, ctx")
+		if strings.Contains(err.Error(), "input.Body") || strings.Contains(err.Error(), "input.Ch") || strings.Contains(err.Error(), "input.Meta") {
+			t.Fatalf("untagged fields must be left to body decoder, got %v", err)
+		}
+	})
+
+	t.Run("from tag fallback chain", func(t *testing.T) {
+		type input struct {
+			Token   string `from:"query=token;header=X-Token;cookie=token;required"`
+			Version int    `from:"header=X-Version;query=v;default=1"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		newRequest := func(query, header, cookie string) *http.Request {
+			r := httptest.NewRequest("GET", "/?"+query, nil)
+			if header != "" {
+				r.Header.Set("X-Token", header)
+			}
+			if cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "token", Value: cookie})
+			}
+			return r
+		}
+
+		for _, tc := range []struct {
+			name                  string
+			query, header, cookie string
+			want                  string
+		}{
+			{name: "all present", query: "token=q", header: "h", cookie: "c", want: "q"},
+			{name: "header and cookie", header: "h", cookie: "c", want: "h"},
+			{name: "cookie only", cookie: "c", want: "c"},
+			{name: "query and cookie", query: "token=q", cookie: "c", want: "q"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				var v input
+				err := unmarshaler.Unmarshal(newRequest(tc.query, tc.header, tc.cookie), &v)
+				assertNoError(t, err)
+				assertEqual(t, tc.want, v.Token)
+				assertEqual(t, 1, v.Version)
+			})
+		}
+
+		t.Run("all absent", func(t *testing.T) {
+			var v input
+			err := unmarshaler.Unmarshal(newRequest("", "", ""), &v)
+			if !errors.Is(err, httpio.ErrMissingRequired) {
+				t.Fatalf("expected ErrMissingRequired, got %v", err)
+			}
+		})
+
+		t.Run("header before query", func(t *testing.T) {
+			r := newRequest("token=q&v=2", "", "")
+			r.Header.Set("X-Version", "3")
+			var v input
+			err := unmarshaler.Unmarshal(r, &v)
+			assertNoError(t, err)
+			assertEqual(t, 3, v.Version)
+		})
+
+		type badInput struct {
+			Token string `from:"meta=method"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("duplicate external names", func(t *testing.T) {
+		type input struct {
+			ID    int `query:"id"`
+			IDToo int `query:"id"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err)
+		assertContains(t, err.Error(), `query "id" is used by both input.ID and input.IDToo`)
+
+		type nested struct {
+			B int `query:"b"`
+		}
+		type nestedInput struct {
+			A  nested `query:"a"`
+			AB int    `query:"a.b"`
+		}
+
+		_, err = httpio.NewUnmarshaler[nestedInput]()
+		assertError(t, err)
+		assertContains(t, err.Error(), `query "a.b" is used by both nested.B and nestedInput.AB`)
+
+		type differentSources struct {
+			QueryID  int `query:"id"`
+			HeaderID int `header:"id"`
+		}
+		_, err = httpio.NewUnmarshaler[differentSources]()
+		assertNoError(t, err)
+	})
+
+	t.Run("slice of pointers and pointer to slice", func(t *testing.T) {
+		type input struct {
+			IDs   []*int    `query:"id"`
+			Tags  *[]string `query:"tag"`
+			Codes []*int    `query:"code,unique"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?id=1&id=2&tag=a&tag=b&code=3&code=3", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 2, len(v.IDs))
+		assertEqual(t, 1, *v.IDs[0])
+		assertEqual(t, 2, *v.IDs[1])
+		if v.IDs[0] == v.IDs[1] {
+			t.Fatal("expected each element to be allocated separately")
+		}
+		if v.Tags == nil {
+			t.Fatal("expected tags to be allocated")
+		}
+		assertEqual(t, "a,b", strings.Join(*v.Tags, ","))
+		assertEqual(t, 1, len(v.Codes))
+
+		r = httptest.NewRequest("GET", "/", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, (*[]string)(nil), v.Tags)
+		assertEqual(t, 0, len(v.IDs))
+
+		r = httptest.NewRequest("GET", "/?id=x", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+	})
+
+	t.Run("header case insensitive", func(t *testing.T) {
+		type input struct {
+			Auth string `header:"Authorization"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header["authorization"] = []string{"Bearer token"}
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "Bearer token", v.Auth)
+
+		type many struct {
+			Auth    string  `header:"Authorization"`
+			Agent   string  `header:"User-Agent"`
+			Trace   *string `header:"X-Trace-Id"`
+			Request string  `header:"X-Request-Id"`
+		}
+		manyUnmarshaler, err := httpio.NewUnmarshaler[many]()
+		assertNoError(t, err)
+
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header["user-agent"] = []string{"curl"}
+		r.Header["x-request-id"] = []string{"lower"}
+		r.Header["X-REQUEST-ID"] = []string{"upper"}
+		r.Header.Set("Authorization", "Bearer token")
+		var m many
+		err = manyUnmarshaler.Unmarshal(r, &m)
+		assertNoError(t, err)
+		assertEqual(t, "Bearer token", m.Auth)
+		assertEqual(t, "curl", m.Agent)
+		assertEqual(t, (*string)(nil), m.Trace)
+		// of keys differing only in case the first in sorted order wins
+		assertEqual(t, "upper", m.Request)
+	})
+
+	t.Run("header exact match", func(t *testing.T) {
+		type input struct {
+			Custom string `header:"x-custom-ID"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithHeaderCanonicalization(false))
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header["x-custom-ID"] = []string{"exact"}
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "exact", v.Custom)
+
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Custom-Id", "canonical")
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "", v.Custom)
+	})
+
+	t.Run("raw body field", func(t *testing.T) {
+		type input struct {
+			Signature string `header:"X-Signature"`
+			Event     string `json:"event"`
+			Payload   []byte `body:"raw" json:"-"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		const body = `{"event":"push"}`
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-Signature", "sha256=abc")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "sha256=abc", v.Signature)
+		assertEqual(t, "push", v.Event)
+		assertEqual(t, body, string(v.Payload))
+
+		rest, err := io.ReadAll(r.Body)
+		assertNoError(t, err)
+		assertEqual(t, body, string(rest))
+
+		// content type without registered decoder
+		r = httptest.NewRequest("POST", "/", strings.NewReader("a=b"))
+		r.Header.Set("Content-Type", "text/plain")
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "a=b", string(v.Payload))
+
+		type twoRaw struct {
+			A []byte `body:"raw"`
+			B string `body:"raw"`
+		}
+		_, err = httpio.NewUnmarshaler[twoRaw]()
+		assertError(t, err)
+
+		type badRaw struct {
+			A int `body:"raw"`
+		}
+		_, err = httpio.NewUnmarshaler[badRaw]()
+		assertError(t, err)
+	})
+
+	t.Run("query catch-all", func(t *testing.T) {
+		type input struct {
+			ID   int                 `query:"id"`
+			All  map[string][]string `query:"*"`
+			Rest url.Values          `query:"*,unmapped"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err) // two catch-all fields
+
+		type allInput struct {
+			ID  int                 `query:"id"`
+			All map[string][]string `query:"*"`
+		}
+		type restInput struct {
+			ID     int        `query:"id"`
+			Filter url.Values `query:"filter."`
+			Rest   url.Values `query:"*,unmapped"`
+		}
+
+		all, err := httpio.NewUnmarshaler[allInput]()
+		assertNoError(t, err)
+		rest, err := httpio.NewUnmarshaler[restInput]()
+		assertNoError(t, err)
+
+		const query = "/?id=1&tag=a&tag=b&filter.name=x"
+
+		var a allInput
+		err = all.Unmarshal(httptest.NewRequest("GET", query, nil), &a)
+		assertNoError(t, err)
+		assertEqual(t, 1, a.ID)
+		assertEqual(t, "filter.name=x&id=1&tag=a&tag=b", url.Values(a.All).Encode())
+
+		var v restInput
+		err = rest.Unmarshal(httptest.NewRequest("GET", query, nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.ID)
+		assertEqual(t, "x", v.Filter.Get("name"))
+		assertEqual(t, "tag=a&tag=b", v.Rest.Encode())
+
+		type badInput struct {
+			All map[string]string `query:"*"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("decode", func(t *testing.T) {
+		type name struct {
+			First string `query:"first"`
+		}
+		type input struct {
+			ID   int  `query:"id"`
+			Name name `query:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?id=1&name.first=John&name_first=Jane", nil)
+		v, err := unmarshaler.Decode(r)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.ID)
+		assertEqual(t, "John", v.Name.First)
+
+		for range 2 {
+			v, err = httpio.Decode[input](r)
+			assertNoError(t, err)
+			assertEqual(t, 1, v.ID)
+			assertEqual(t, "John", v.Name.First)
+		}
+
+		// options affecting compilation must not reuse default compiled type
+		v, err = httpio.Decode[input](r, httpio.WithDelimiter("_"))
+		assertNoError(t, err)
+		assertEqual(t, "Jane", v.Name.First)
+
+		_, err = httpio.Decode[input](httptest.NewRequest("GET", "/?id=abc", nil))
+		assertError(t, err)
+	})
+
+	t.Run("raw query scan", func(t *testing.T) {
+		type name struct {
+			First string `query:"first"`
+		}
+		type input struct {
+			ID    int      `query:"id"`
+			Tags  []string `query:"tag"`
+			Name  name     `query:"name"`
+			Email string   `query:"email"`
+		}
+
+		scan, err := httpio.NewUnmarshaler[input](httpio.WithRawQueryScan())
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?id=1&tag=a&name.first=J%C3%B6rg&tag=b+c&email=a%40b.c&id=2&x;y=1", nil)
+		var v input
+		err = scan.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 2, v.ID) // last value wins
+		assertEqual(t, "a|b c", strings.Join(v.Tags, "|"))
+		assertEqual(t, "Jörg", v.Name.First)
+		assertEqual(t, "a@b.c", v.Email)
+
+		r = httptest.NewRequest("GET", "/?id=abc&id=1", nil)
+		err = scan.Unmarshal(r, &v)
+		assertError(t, err)
+	})
+
+	t.Run("bracket nesting", func(t *testing.T) {
+		type street struct {
+			Name string `query:"name"`
+		}
+		type address struct {
+			City   string `query:"city"`
+			Street street `query:"street"`
+		}
+		type input struct {
+			Name struct {
+				First string `query:"first"`
+				Last  string `query:"last"`
+			} `query:"name"`
+			Address address `query:"address"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithBracketNesting())
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?name[first]=John&name.last=Doe&address[street][name]=Main&address[city]=Oslo&address.city=Bergen", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "John", v.Name.First)
+		assertEqual(t, "Doe", v.Name.Last)
+		assertEqual(t, "Main", v.Address.Street.Name)
+		assertEqual(t, "Bergen", v.Address.City)
+
+		plain, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		v = input{}
+		err = plain.Unmarshal(httptest.NewRequest("GET", "/?name[first]=John", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, "", v.Name.First)
+	})
+
+	t.Run("max body bytes", func(t *testing.T) {
+		type input struct {
+			Name string `json:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithMaxBodyBytes(16))
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"john"}`))
+		r.Header.Set("Content-Type", "application/json")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "john", v.Name)
+
+		r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"`+strings.Repeat("a", 64)+`"}`))
+		r.Header.Set("Content-Type", "application/json")
+		err = unmarshaler.Unmarshal(r, &v)
+		var tooLarge *http.MaxBytesError
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("expected *http.MaxBytesError, got %v", err)
+		}
+		assertEqual(t, int64(16), tooLarge.Limit)
+		assertContains(t, err.Error(), "request body exceeds 16 bytes")
+
+		r = httptest.NewRequest("POST", "/", strings.NewReader(`<input><name>`+strings.Repeat("a", 64)+`</name></input>`))
+		r.Header.Set("Content-Type", "application/xml")
+		err = unmarshaler.Unmarshal(r, &v)
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("expected *http.MaxBytesError, got %v", err)
+		}
+	})
+
+	t.Run("max body bytes limits forms", func(t *testing.T) {
+		type input struct {
+			Name string `form:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithMaxBodyBytes(16))
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader("name=john"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "john", v.Name)
+
+		r = httptest.NewRequest("POST", "/", strings.NewReader("name="+strings.Repeat("a", 64)))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		err = unmarshaler.Unmarshal(r, &v)
+		var tooLarge *http.MaxBytesError
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("expected *http.MaxBytesError, got %v", err)
+		}
+		assertEqual(t, int64(16), tooLarge.Limit)
+
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		assertNoError(t, mw.WriteField("name", strings.Repeat("a", 64)))
+		assertNoError(t, mw.Close())
+		r = httptest.NewRequest("POST", "/", &buf)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		err = unmarshaler.Unmarshal(r, &v)
+		if err == nil {
+			t.Fatal("expected error for oversized multipart form")
+		}
+		assertContains(t, err.Error(), "request body too large")
+	})
+
+	t.Run("big numbers", func(t *testing.T) {
+		type input struct {
+			Amount  *big.Int     `query:"amount"`
+			Rate    big.Float    `query:"rate"`
+			Rates   []*big.Float `query:"rates"`
+			Amounts []big.Int    `query:"amounts,csv"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?amount=123456789012345678901234567890&rate=0.000000000000000000001&rates=1.5&rates=2.25&amounts=1,99999999999999999999", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "123456789012345678901234567890", v.Amount.String())
+		assertEqual(t, "1e-21", v.Rate.Text('g', 10))
+		assertEqual(t, 2, len(v.Rates))
+		assertEqual(t, "2.25", v.Rates[1].Text('f', 2))
+		assertEqual(t, 2, len(v.Amounts))
+		assertEqual(t, "99999999999999999999", v.Amounts[1].String())
+
+		for _, query := range []string{"amount=12x", "rate=abc", "rates=1&rates=x"} {
+			err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?"+query, nil), &input{})
+			assertError(t, err)
+		}
+	})
+
+	t.Run("path wildcard split", func(t *testing.T) {
+		type whole struct {
+			Rest string `path:"rest"`
+		}
+		type split struct {
+			Segments []string `path:"rest,split"`
+		}
+
+		wholeUnmarshaler, err := httpio.NewUnmarshaler[whole]()
+		assertNoError(t, err)
+		splitUnmarshaler, err := httpio.NewUnmarshaler[split]()
+		assertNoError(t, err)
+
+		var (
+			w       whole
+			s       split
+			errs    []error
+			handled bool
+		)
+		mux := http.NewServeMux()
+		mux.HandleFunc("/files/{rest...}", func(_ http.ResponseWriter, r *http.Request) {
+			handled = true
+			errs = append(errs, wholeUnmarshaler.Unmarshal(r, &w), splitUnmarshaler.Unmarshal(r, &s))
+		})
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/files/a/b/c", nil))
+		if !handled {
+			t.Fatal("route was not matched")
+		}
+		assertNoError(t, errors.Join(errs...))
+		assertEqual(t, "a/b/c", w.Rest)
+		assertEqual(t, "a|b|c", strings.Join(s.Segments, "|"))
+
+		type badInput struct {
+			Rest string `path:"rest,split"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("trim and case modifiers", func(t *testing.T) {
+		type input struct {
+			Email   string   `query:"email,trim,lower"`
+			Code    *string  `query:"code,upper"`
+			Age     int      `query:"age,trim"`
+			Tags    []string `query:"tags,csv,trim,lower"`
+			Country string   `query:"country,trim,upper,oneof=NO SE"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		q := url.Values{
+			"email":   {"  John@Example.COM "},
+			"code":    {"ab1"},
+			"age":     {" 30 "},
+			"tags":    {" Go , HTTP"},
+			"country": {" no"},
+		}
+		var v input
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?"+q.Encode(), nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, "john@example.com", v.Email)
+		assertEqual(t, "AB1", *v.Code)
+		assertEqual(t, 30, v.Age)
+		assertEqual(t, "go|http", strings.Join(v.Tags, "|"))
+		assertEqual(t, "NO", v.Country)
+
+		type badInput struct {
+			Name string `query:"name,lower,upper"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("unmarshal sources", func(t *testing.T) {
+		type input struct {
+			ID      int    `path:"id"`
+			Page    int    `query:"page"`
+			Token   string `header:"X-Token"`
+			Session string `cookie:"session"`
+			Name    string `json:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.UnmarshalSources(&v, httpio.Sources{
+			Query:   url.Values{"page": {"2"}},
+			Header:  http.Header{"X-Token": {"secret"}, "Content-Type": {"application/json"}},
+			Path:    map[string]string{"id": "7"},
+			Cookies: []*http.Cookie{{Name: "session", Value: "s1"}},
+			Body:    strings.NewReader(`{"name":"john"}`),
+		})
+		assertNoError(t, err)
+		assertEqual(t, 7, v.ID)
+		assertEqual(t, 2, v.Page)
+		assertEqual(t, "secret", v.Token)
+		assertEqual(t, "s1", v.Session)
+		assertEqual(t, "john", v.Name)
+
+		v = input{}
+		err = unmarshaler.UnmarshalSources(&v, httpio.Sources{})
+		assertNoError(t, err)
+		assertEqual(t, input{}, v)
+
+		err = unmarshaler.UnmarshalSources(&v, httpio.Sources{Path: map[string]string{"id": "x"}})
+		assertError(t, err)
+	})
+
+	t.Run("sql null types", func(t *testing.T) {
+		type input struct {
+			Name   sql.NullString  `query:"name"`
+			Age    sql.NullInt64   `query:"age"`
+			Score  sql.NullFloat64 `query:"score"`
+			Active sql.NullBool    `query:"active"`
+			Level  sql.Null[int32] `query:"level"`
+			IDs    []sql.NullInt32 `query:"id"`
+			Alias  *sql.NullString `query:"alias"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		fields := unmarshaler.Fields()
+		for _, f := range fields {
+			if strings.Contains(f.Name, ".") {
+				t.Fatalf("expected sql null types to be scalars, got %s", f.Name)
+			}
+		}
+
+		var v input
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?name=john&age=30&score=1.5&active=true&level=3&id=1&id=2", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, sql.NullString{String: "john", Valid: true}, v.Name)
+		assertEqual(t, sql.NullInt64{Int64: 30, Valid: true}, v.Age)
+		assertEqual(t, sql.NullFloat64{Float64: 1.5, Valid: true}, v.Score)
+		assertEqual(t, sql.NullBool{Bool: true, Valid: true}, v.Active)
+		assertEqual(t, sql.Null[int32]{V: 3, Valid: true}, v.Level)
+		assertEqual(t, 2, len(v.IDs))
+		assertEqual(t, sql.NullInt32{Int32: 2, Valid: true}, v.IDs[1])
+		assertEqual(t, (*sql.NullString)(nil), v.Alias)
+
+		v = input{}
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?alias=", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, false, v.Name.Valid)
+		assertEqual(t, false, v.Age.Valid)
+		assertEqual(t, sql.NullString{String: "", Valid: true}, *v.Alias)
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?age=abc", nil), &v)
+		assertError(t, err)
+	})
+
+	t.Run("default source", func(t *testing.T) {
+		type Embedded struct {
+			Page int `query:"page"`
+		}
+		type input struct {
+			Embedded
+			ID      int
+			Tracing string
+			Name    string `query:"name"`
+		}
+
+		r := httptest.NewRequest("GET", "/?ID=1&Tracing=q&name=john&page=2", nil)
+		r.Header.Set("Tracing", "h")
+
+		none, err := httpio.NewUnmarshaler[input](httpio.WithDefaultSource(httpio.SourceNone))
+		assertNoError(t, err)
+		var v input
+		err = none.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, input{Embedded: Embedded{Page: 2}, Name: "john"}, v)
+
+		header, err := httpio.NewUnmarshaler[input](httpio.WithDefaultSource(httpio.SourceHeader))
+		assertNoError(t, err)
+		v = input{}
+		err = header.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, input{Embedded: Embedded{Page: 2}, Tracing: "h", Name: "john"}, v)
+
+		query, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		v = input{}
+		err = query.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, input{Embedded: Embedded{Page: 2}, ID: 1, Tracing: "q", Name: "john"}, v)
+
+		_, err = httpio.NewUnmarshaler[input](httpio.WithDefaultSource(httpio.SourceMeta))
+		assertError(t, err)
+	})
+
+	t.Run("errors name external parameter", func(t *testing.T) {
+		type input struct {
+			Age   int    `query:"age"`
+			Token string `header:"x-token,required"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithBestEffort())
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?age=abc", nil), &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), `query parameter "age" (input.Age): parse int`)
+		assertContains(t, err.Error(), `header "X-Token" (input.Token): required value is missing`)
+	})
+
+	t.Run("decompression", func(t *testing.T) {
+		type input struct {
+			Name string `json:"name"`
+		}
+
+		compress := func(encoding, s string) *bytes.Buffer {
+			var buf bytes.Buffer
+			var w io.WriteCloser
+			if encoding == "gzip" {
+				w = gzip.NewWriter(&buf)
+			} else {
+				w = zlib.NewWriter(&buf)
+			}
+			_, err := io.WriteString(w, s)
+			assertNoError(t, err)
+			assertNoError(t, w.Close())
+			return &buf
+		}
+		newRequest := func(encoding, body string) *http.Request {
+			r := httptest.NewRequest("POST", "/", compress(encoding, body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Content-Encoding", encoding)
+			return r
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithDecompression(), httpio.WithMaxBodyBytes(1024))
+		assertNoError(t, err)
+
+		for _, encoding := range []string{"gzip", "deflate"} {
+			var v input
+			err = unmarshaler.Unmarshal(newRequest(encoding, `{"name":"john"}`), &v)
+			assertNoError(t, err)
+			assertEqual(t, "john", v.Name)
+		}
+
+		// compresses well below the limit, but expands above it
+		bomb := `{"name":"` + strings.Repeat("a", 4096) + `"}`
+		err = unmarshaler.Unmarshal(newRequest("gzip", bomb), &input{})
+		var tooLarge *http.MaxBytesError
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("expected *http.MaxBytesError, got %v", err)
+		}
+
+		r := newRequest("gzip", `{"name":"john"}`)
+		r.Header.Set("Content-Encoding", "br")
+		err = unmarshaler.Unmarshal(r, &input{})
+		assertError(t, err)
+
+		plain, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		err = plain.Unmarshal(newRequest("gzip", `{"name":"john"}`), &input{})
+		assertError(t, err)
+	})
+
+	t.Run("auth scheme modifiers", func(t *testing.T) {
+		type input struct {
+			Token string `header:"Authorization,bearer,required"`
+		}
+		type basicInput struct {
+			Credentials string `header:"Authorization,basic"`
+		}
+		type schemeInput struct {
+			Key *string `header:"Authorization,scheme=ApiKey"`
+		}
+
+		bearer, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		basic, err := httpio.NewUnmarshaler[basicInput]()
+		assertNoError(t, err)
+		scheme, err := httpio.NewUnmarshaler[schemeInput]()
+		assertNoError(t, err)
+
+		newRequest := func(auth string) *http.Request {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Authorization", auth)
+			return r
+		}
+
+		var v input
+		err = bearer.Unmarshal(newRequest("bearer abc.def"), &v)
+		assertNoError(t, err)
+		assertEqual(t, "abc.def", v.Token)
+
+		for _, auth := range []string{"abc.def", "Basic abc", "Bearer "} {
+			err = bearer.Unmarshal(newRequest(auth), &input{})
+			if !errors.Is(err, httpio.ErrMissingRequired) {
+				t.Fatalf("%q: expected ErrMissingRequired, got %v", auth, err)
+			}
+		}
+
+		var b basicInput
+		err = basic.Unmarshal(newRequest("Basic "+base64.StdEncoding.EncodeToString([]byte("user:p:ss"))), &b)
+		assertNoError(t, err)
+		assertEqual(t, "user:p:ss", b.Credentials)
+
+		err = basic.Unmarshal(newRequest("Basic !!!"), &b)
+		assertError(t, err)
+
+		var s schemeInput
+		err = scheme.Unmarshal(newRequest("Bearer abc"), &s)
+		assertNoError(t, err)
+		assertEqual(t, (*string)(nil), s.Key)
+		err = scheme.Unmarshal(newRequest("ApiKey k1"), &s)
+		assertNoError(t, err)
+		assertEqual(t, "k1", *s.Key)
+	})
+
+	t.Run("body error", func(t *testing.T) {
+		type input struct {
+			ID   int    `query:"id"`
+			Name string `json:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":`+"\n"+`1}`))
+		r.Header.Set("Content-Type", "application/json; charset=utf-8")
+		err = unmarshaler.Unmarshal(r, &input{})
+		var bodyErr *httpio.BodyError
+		if !errors.As(err, &bodyErr) {
+			t.Fatalf("expected *httpio.BodyError, got %v", err)
+		}
+		assertEqual(t, "application/json", bodyErr.MediaType)
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("expected *json.UnmarshalTypeError, got %v", err)
+		}
+
+		// empty body is tolerated
+		r = httptest.NewRequest("POST", "/", nil)
+		r.Header.Set("Content-Type", "application/json")
+		err = unmarshaler.Unmarshal(r, &input{})
+		assertNoError(t, err)
+
+		// other sources are not body errors
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?id=x", nil), &input{})
+		assertError(t, err)
+		if errors.As(err, &bodyErr) {
+			t.Fatalf("unexpected body error: %v", err)
+		}
+	})
+
+	t.Run("package-level unmarshal", func(t *testing.T) {
+		type input struct {
+			ID   int    `query:"id"`
+			Name string `header:"X-Name"`
+		}
+
+		r := httptest.NewRequest("GET", "/?id=1", nil)
+		r.Header.Set("X-Name", "john")
+		for range 2 {
+			var v input
+			err := httpio.Unmarshal(r, &v)
+			assertNoError(t, err)
+			assertEqual(t, input{ID: 1, Name: "john"}, v)
+		}
+
+		type badInput struct {
+			ID int `query:"id,bogus"`
+		}
+		err := httpio.Unmarshal(r, &badInput{})
+		assertError(t, err)
+	})
+
+	t.Run("text unmarshaler error", func(t *testing.T) {
+		type input struct {
+			Version semver `query:"version"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?version=1.2", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, semver{Major: 1, Minor: 2}, v.Version)
+
+		r = httptest.NewRequest("GET", "/?version=latest", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		if !errors.Is(err, errBadSemver) {
+			t.Fatalf("expected errBadSemver, got %v", err)
+		}
+		assertContains(t, err.Error(), `query parameter "version"`)
+		assertContains(t, err.Error(), `"latest"`)
+		assertContains(t, err.Error(), "httpio_test.semver")
+	})
+
+	t.Run("multiple source tags", func(t *testing.T) {
+		type input struct {
+			RequestID string `header:"X-Request-ID" query:"request_id"`
+			Page      int    `query:"page" header:"X-Page,default=1"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		for _, tc := range []struct {
+			name   string
+			query  string
+			header string
+			want   string
+		}{
+			{name: "header present", header: "h", want: "h"},
+			{name: "query present", query: "request_id=q", want: "q"},
+			{name: "both present", query: "request_id=q", header: "h", want: "h"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				r := httptest.NewRequest("GET", "/?"+tc.query, nil)
+				if tc.header != "" {
+					r.Header.Set("X-Request-ID", tc.header)
+				}
+				var v input
+				err := unmarshaler.Unmarshal(r, &v)
+				assertNoError(t, err)
+				assertEqual(t, input{RequestID: tc.want, Page: 1}, v)
+			})
+		}
+
+		t.Run("order follows tags", func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/?page=2", nil)
+			r.Header.Set("X-Page", "3")
+			var v input
+			err := unmarshaler.Unmarshal(r, &v)
+			assertNoError(t, err)
+			assertEqual(t, 2, v.Page)
+		})
+
+		type badInput struct {
+			ID int `query:"id,required" header:"X-ID,default=1"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "query and header tags have different modifiers")
+	})
+
+	t.Run("strict content type", func(t *testing.T) {
+		type input struct {
+			Name string `json:"name"`
+		}
+
+		newRequest := func(ct string) *http.Request {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"john"}`))
+			r.Header.Set("Content-Type", ct)
+			return r
+		}
+
+		lenient, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		strict, err := httpio.NewUnmarshaler[input](httpio.WithStrictContentType())
+		assertNoError(t, err)
+
+		for _, tc := range []struct {
+			ct         string
+			wantStrict string
+		}{
+			{ct: "application/json", wantStrict: "john"},
+			{ct: "application/json; charset=utf-8", wantStrict: ""},
+			{ct: "Application/JSON", wantStrict: ""},
+		} {
+			t.Run(tc.ct, func(t *testing.T) {
+				var v input
+				err := lenient.Unmarshal(newRequest(tc.ct), &v)
+				assertNoError(t, err)
+				assertEqual(t, "john", v.Name)
+
+				v = input{}
+				err = strict.Unmarshal(newRequest(tc.ct), &v)
+				assertNoError(t, err)
+				assertEqual(t, tc.wantStrict, v.Name)
+			})
+		}
+	})
+
+	t.Run("integer base modifier", func(t *testing.T) {
+		type input struct {
+			Mask  uint32  `query:"mask,base=0"`
+			Mode  int     `query:"mode,base=0"`
+			Flags int8    `query:"flags,base=0"`
+			Color *uint32 `query:"color,base=16"`
+			Plain int     `query:"plain"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?mask=0xFF&mode=0755&flags=0b101&color=ff8800&plain=010", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, uint32(0xFF), v.Mask)
+		assertEqual(t, 0o755, v.Mode)
+		assertEqual(t, int8(5), v.Flags)
+		assertEqual(t, uint32(0xff8800), *v.Color)
+		assertEqual(t, 10, v.Plain)
+
+		r = httptest.NewRequest("GET", "/?mask=0xZZ", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+
+		type badBase struct {
+			Mask int `query:"mask,base=1"`
+		}
+		_, err = httpio.NewUnmarshaler[badBase]()
+		assertError(t, err)
+
+		type badType struct {
+			Name string `query:"name,base=16"`
+		}
+		_, err = httpio.NewUnmarshaler[badType]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "base modifier requires integer type")
+	})
+
+	t.Run("field hook", func(t *testing.T) {
+		type input struct {
+			ID    int      `path:"id"`
+			Tags  []string `query:"tag"`
+			Token string   `header:"X-Token,bearer"`
+			Page  int      `query:"page,default=1"`
+			Debug bool     `query:"debug"`
+		}
+
+		var seen []string
+		unmarshaler, err := httpio.NewUnmarshaler[input](
+			httpio.WithPathLookuper(func(r *http.Request, name string) (string, bool) {
+				return "42", name == "id"
+			}),
+			httpio.WithFieldHook(func(field httpio.FieldInfo, rawValue []string) {
+				seen = append(seen, fmt.Sprintf("%s %s %s=%s", field.Source, field.StructField, field.Name, strings.Join(rawValue, "|")))
+			}),
+		)
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?tag=a&tag=b", nil)
+		r.Header.Set("X-Token", "Basic abc")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.Page)
+
+		slices.Sort(seen)
+		assertEqual(t, "path input.ID id=42\nquery input.Tags tag=a|b", strings.Join(seen, "\n"))
+	})
+
+	t.Run("csv in headers and cookies", func(t *testing.T) {
+		type input struct {
+			Tags    []string `header:"X-Tags,csv"`
+			IDs     []int    `header:"X-IDs,csv,unique"`
+			Recent  []string `cookie:"recent,csv"`
+			Filters []string `cookie:"filters,sep=|"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Tags", "a,b,c")
+		r.Header.Add("X-IDs", "1,2")
+		r.Header.Add("X-IDs", "2,3")
+		r.AddCookie(&http.Cookie{Name: "recent", Value: "x,y"})
+		r.AddCookie(&http.Cookie{Name: "filters", Value: "p|q"})
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "a b c", strings.Join(v.Tags, " "))
+		assertEqual(t, "[1 2 3]", fmt.Sprint(v.IDs))
+		assertEqual(t, "x y", strings.Join(v.Recent, " "))
+		assertEqual(t, "p q", strings.Join(v.Filters, " "))
+	})
+
+	t.Run("require all path", func(t *testing.T) {
+		type input struct {
+			UserID int    `path:"user_id"`
+			Token  string `from:"path=token;query=token"`
+		}
+
+		lookuper := func(r *http.Request, name string) (string, bool) {
+			if name == "userId" {
+				return "1", true
+			}
+			return "", false
+		}
+
+		lenient, err := httpio.NewUnmarshaler[input](httpio.WithPathLookuper(lookuper))
+		assertNoError(t, err)
+		strict, err := httpio.NewUnmarshaler[input](
+			httpio.WithPathLookuper(lookuper),
+			httpio.WithRequireAllPath(),
+		)
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/users/1?token=t", nil)
+		var v input
+		err = lenient.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, input{Token: "t"}, v)
+
+		err = strict.Unmarshal(r, &v)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertContains(t, err.Error(), `path parameter "user_id"`)
+	})
+
+	t.Run("tri-state bool pointer", func(t *testing.T) {
+		type input struct {
+			Active *bool   `query:"active"`
+			Name   *string `query:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithBestEffort())
+		assertNoError(t, err)
+
+		decode := func(query string) (input, error) {
+			var v input
+			err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?"+query, nil), &v)
+			return v, err
+		}
+
+		v, err := decode("")
+		assertNoError(t, err)
+		assertEqual(t, (*bool)(nil), v.Active)
+
+		v, err = decode("active=true")
+		assertNoError(t, err)
+		assertEqual(t, true, *v.Active)
+
+		v, err = decode("active=false")
+		assertNoError(t, err)
+		assertEqual(t, false, *v.Active)
+
+		// present but empty is not a bool, pointer stays nil
+		v, err = decode("active=&name=")
+		assertError(t, err)
+		assertContains(t, err.Error(), `query parameter "active"`)
+		assertEqual(t, (*bool)(nil), v.Active)
+		assertEqual(t, "", *v.Name)
+	})
+
+	t.Run("empty values", func(t *testing.T) {
+		type input struct {
+			Q      string   `query:"q,required"`
+			Filter *string  `query:"filter"`
+			Sort   string   `query:"sort,default=name"`
+			Tags   []string `query:"tag"`
+		}
+
+		r := httptest.NewRequest("GET", "/?q=&filter=&sort=&tag=a&tag=", nil)
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		v := input{Q: "previous"}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "", v.Q)
+		assertEqual(t, "", *v.Filter)
+		assertEqual(t, "", v.Sort)
+		assertEqual(t, `["a" ""]`, fmt.Sprintf("%q", v.Tags))
+
+		absent, err := httpio.NewUnmarshaler[input](httpio.WithEmptyValuesAsAbsent())
+		assertNoError(t, err)
+
+		v = input{}
+		err = absent.Unmarshal(r, &v)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertContains(t, err.Error(), `query parameter "q"`)
+
+		v = input{}
+		err = absent.Unmarshal(httptest.NewRequest("GET", "/?q=x&filter=&sort=&tag=a&tag=", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, "x", v.Q)
+		assertEqual(t, (*string)(nil), v.Filter)
+		assertEqual(t, "name", v.Sort)
+		assertEqual(t, `["a"]`, fmt.Sprintf("%q", v.Tags))
+	})
+
+	t.Run("empty values as absent in other sources", func(t *testing.T) {
+		type input struct {
+			Name   string  `form:"name,default=anon"`
+			Agent  *string `header:"X-Agent"`
+			Theme  string  `cookie:"theme,default=light"`
+			Token  string  `header:"X-Token,required"`
+			Locale string  `from:"header=X-Locale;query=locale"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithEmptyValuesAsAbsent())
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("POST", "/?locale=en", strings.NewReader("name="))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set("X-Agent", "")
+		r.Header.Set("X-Token", "abc")
+		r.Header.Set("X-Locale", "")
+		r.AddCookie(&http.Cookie{Name: "theme", Value: ""})
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "anon", v.Name)
+		assertEqual(t, (*string)(nil), v.Agent)
+		assertEqual(t, "light", v.Theme)
+		assertEqual(t, "abc", v.Token)
+		assertEqual(t, "en", v.Locale)
+
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Token", "")
+		err = unmarshaler.Unmarshal(r, &v)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertContains(t, err.Error(), `header "X-Token"`)
+	})
+
+	t.Run("empty values as absent in wildcard fields", func(t *testing.T) {
+		type input struct {
+			All    url.Values  `query:"*"`
+			Custom http.Header `header:"X-Custom-*"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithEmptyValuesAsAbsent())
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?a=&b=2&c=3", nil)
+		r.Header.Set("X-Custom-A", "")
+		r.Header.Set("X-Custom-B", "bee")
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "b=2&c=3", v.All.Encode())
+		assertEqual(t, 1, len(v.Custom))
+		assertEqual(t, "bee", v.Custom.Get("X-Custom-B"))
+	})
+
+	t.Run("header prefix wildcard", func(t *testing.T) {
+		type input struct {
+			Custom http.Header         `header:"x-custom-*"`
+			All    map[string][]string `header:"*"`
+			Agent  string              `header:"User-Agent"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Custom-A", "1")
+		r.Header.Add("X-Custom-B", "2")
+		r.Header.Add("X-Custom-B", "3")
+		r.Header["x-custom-c"] = []string{"4"}
+		r.Header.Set("X-Other", "5")
+		r.Header.Set("User-Agent", "test")
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 3, len(v.Custom))
+		assertEqual(t, "1", v.Custom.Get("X-Custom-A"))
+		assertEqual(t, "2,3", strings.Join(v.Custom["X-Custom-B"], ","))
+		assertEqual(t, "4", v.Custom.Get("X-Custom-C"))
+		assertEqual(t, "5", v.All["X-Other"][0])
+		assertEqual(t, "test", v.Agent)
+
+		fields := unmarshaler.Fields()
+		assertEqual(t, "*", fields[0].Name)
+		assertEqual(t, "X-Custom-*", fields[2].Name)
+
+		type badInput struct {
+			Custom string `header:"X-Custom-*"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("json options", func(t *testing.T) {
+		type input struct {
+			Name  string `json:"name"`
+			Count any    `json:"count"`
+		}
+
+		newRequest := func(body string) *http.Request {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			return r
+		}
+
+		strict := httpio.WithJSONOptions(func(dec *json.Decoder) {
+			dec.DisallowUnknownFields()
+			dec.UseNumber()
+		})
+		unmarshaler, err := httpio.NewUnmarshaler[input](strict)
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(newRequest(`{"name":"john","count":10}`), &v)
+		assertNoError(t, err)
+		assertEqual(t, any(json.Number("10")), v.Count)
+
+		err = unmarshaler.Unmarshal(newRequest(`{"name":"john","extra":true}`), &v)
+		var bodyErr *httpio.BodyError
+		if !errors.As(err, &bodyErr) {
+			t.Fatalf("expected BodyError, got %v", err)
+		}
+		assertContains(t, err.Error(), `unknown field "extra"`)
+
+		_, err = httpio.Decode[input](newRequest(`{"extra":true}`), strict)
+		assertContains(t, err.Error(), `unknown field "extra"`)
+
+		_, err = httpio.Decode[input](newRequest(`{"extra":true}`))
+		assertNoError(t, err)
+	})
+
+	t.Run("char modifier", func(t *testing.T) {
+		type input struct {
+			Sep     rune   `query:"sep,char"`
+			Symbol  *rune  `query:"symbol,char"`
+			Quote   byte   `query:"quote,char"`
+			Marks   []rune `query:"mark,char"`
+			Numeric rune   `query:"numeric"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?"+url.Values{
+			"sep":     {","},
+			"symbol":  {"€"},
+			"quote":   {"'"},
+			"mark":    {"✓", "世"},
+			"numeric": {"44"},
+		}.Encode(), nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, ',', v.Sep)
+		assertEqual(t, '€', *v.Symbol)
+		assertEqual(t, byte('\''), v.Quote)
+		assertEqual(t, "✓世", string(v.Marks))
+		assertEqual(t, rune(44), v.Numeric)
+
+		for _, query := range []string{"sep=ab", "sep=", "quote=%C3%A9"} {
+			err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?"+query, nil), &v)
+			assertError(t, err)
+		}
+
+		type badInput struct {
+			Sep string `query:"sep,char"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("indexed struct slices", func(t *testing.T) {
+		type address struct {
+			City string `query:"city"`
+		}
+		type item struct {
+			Name    string  `query:"name,required"`
+			Qty     int     `query:"qty,default=1"`
+			Address address `query:"address"`
+		}
+		type input struct {
+			Items []item `query:"items"`
+			Page  int    `query:"page"`
+		}
+
+		_, err := httpio.NewUnmarshaler[input]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "unsupported type: slice element httpio_test.item")
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithSliceStructSupport())
+		assertNoError(t, err)
+
+		decode := func(query string) (input, error) {
+			var v input
+			err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?"+query, nil), &v)
+			return v, err
+		}
+
+		v, err := decode("items[1].name=b&items[0].name=a&items[0].qty=3&items[1].address.city=Paris&page=2")
+		assertNoError(t, err)
+		assertEqual(t, 2, len(v.Items))
+		assertEqual(t, item{Name: "a", Qty: 3}, v.Items[0])
+		assertEqual(t, item{Name: "b", Qty: 1, Address: address{City: "Paris"}}, v.Items[1])
+		assertEqual(t, 2, v.Page)
+
+		v, err = decode("page=1")
+		assertNoError(t, err)
+		assertEqual(t, 0, len(v.Items))
+
+		_, err = decode("items[0].name=a&items[2].name=c")
+		assertError(t, err)
+		assertContains(t, err.Error(), `query parameter "items" (input.Items): index 1 is missing`)
+
+		_, err = decode("items[0].qty=2")
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertContains(t, err.Error(), `query parameter "items[0].name" (item.Name)`)
+
+		_, err = decode("items[0].name=a&items[0].qty=x")
+		assertError(t, err)
+		assertContains(t, err.Error(), `query parameter "items[0].qty" (item.Qty)`)
+
+		type nested struct {
+			Groups []struct {
+				Items []item `query:"items"`
+			} `query:"groups"`
+		}
+		_, err = httpio.NewUnmarshaler[nested](httpio.WithSliceStructSupport())
+		assertError(t, err)
+		assertContains(t, err.Error(), "only query fields are supported")
+
+		type untagged struct {
+			Items []item `json:"items"`
+		}
+		_, err = httpio.NewUnmarshaler[untagged](httpio.WithSliceStructSupport())
+		assertNoError(t, err)
+	})
+
+	t.Run("must new unmarshaler", func(t *testing.T) {
+		type input struct {
+			ID int `query:"id"`
+		}
+
+		var v input
+		err := httpio.MustNewUnmarshaler[input]().Unmarshal(httptest.NewRequest("GET", "/?id=1", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.ID)
+
+		defer func() {
+			r := recover()
+			if r == nil {
+				t.Fatal("expected panic for non struct type")
+			}
+			err, ok := r.(error)
+			if !ok {
+				t.Fatalf("expected error panic value, got %T", r)
+			}
+			assertContains(t, err.Error(), "is not a struct")
+		}()
+		httpio.MustNewUnmarshaler[int]()
+	})
+
+	t.Run("embedded pointer allocated lazily", func(t *testing.T) {
+		type Pagination struct {
+			Limit  int `query:"limit"`
+			Offset int `query:"offset"`
+		}
+		type Filter struct {
+			Status string `query:"status"`
+		}
+		type input struct {
+			*Pagination
+			Filter *Filter `query:"filter"`
+			Search string  `query:"search"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?limit=10&offset=20&filter.status=open", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, Pagination{Limit: 10, Offset: 20}, *v.Pagination)
+		assertEqual(t, Filter{Status: "open"}, *v.Filter)
+
+		v = input{}
+		r = httptest.NewRequest("GET", "/?search=go", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, (*Pagination)(nil), v.Pagination)
+		assertEqual(t, (*Filter)(nil), v.Filter)
+		assertEqual(t, "go", v.Search)
+	})
+
+	t.Run("truthy modifier", func(t *testing.T) {
+		type input struct {
+			Debug  bool  `header:"X-Debug,truthy"`
+			Strict bool  `header:"X-Strict"`
+			Cache  *bool `query:"cache,truthy"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		for value, want := range map[string]bool{
+			"on": true, "OFF": false, "yes": true, "No": false,
+			"1": true, "0": false, "true": true, "F": false, "y": true, "n": false,
+		} {
+			r := httptest.NewRequest("GET", "/?cache="+value, nil)
+			r.Header.Set("X-Debug", value)
+			var v input
+			err := unmarshaler.Unmarshal(r, &v)
+			assertNoError(t, err)
+			assertEqual(t, want, v.Debug)
+			assertEqual(t, want, *v.Cache)
+		}
+
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Debug", "maybe")
+		err = unmarshaler.Unmarshal(r, &input{})
+		assertError(t, err)
+		assertContains(t, err.Error(), `"maybe" is not a truthy value`)
+
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Strict", "on")
+		err = unmarshaler.Unmarshal(r, &input{})
+		assertError(t, err)
+
+		type badInput struct {
+			Retries int `header:"X-Retries,truthy"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("reset reused destination", func(t *testing.T) {
+		type input struct {
+			ID   int      `query:"id"`
+			Name string   `query:"name"`
+			Tags []string `query:"tag"`
+			Page *int     `query:"page"`
+		}
+
+		first := httptest.NewRequest("GET", "/?id=1&name=john&tag=a&page=2", nil)
+		second := httptest.NewRequest("GET", "/?id=2", nil)
+
+		plain, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		var v input
+		assertNoError(t, plain.Unmarshal(first, &v))
+		assertNoError(t, plain.Unmarshal(second, &v))
+		assertEqual(t, 2, v.ID)
+		assertEqual(t, "john", v.Name)
+
+		reset, err := httpio.NewUnmarshaler[input](httpio.WithReset())
+		assertNoError(t, err)
+		v = input{}
+		assertNoError(t, reset.Unmarshal(first, &v))
+		assertEqual(t, "john", v.Name)
+		assertEqual(t, 2, *v.Page)
+		assertNoError(t, reset.Unmarshal(second, &v))
+		assertEqual(t, 2, v.ID)
+		assertEqual(t, "", v.Name)
+		assertEqual(t, 0, len(v.Tags))
+		assertEqual(t, (*int)(nil), v.Page)
+	})
+
+	t.Run("raw and base64 bytes", func(t *testing.T) {
+		type rawInput struct {
+			Signature []byte `header:"X-Signature,raw"`
+		}
+		type base64Input struct {
+			Signature []byte  `header:"X-Signature,base64"`
+			Digest    *[]byte `query:"digest,base64"`
+		}
+
+		raw, err := httpio.NewUnmarshaler[rawInput]()
+		assertNoError(t, err)
+		decoded, err := httpio.NewUnmarshaler[base64Input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?digest=AAH%2F", nil)
+		r.Header.Set("X-Signature", "c2lnbmVk")
+
+		var rv rawInput
+		err = raw.Unmarshal(r, &rv)
+		assertNoError(t, err)
+		assertEqual(t, "c2lnbmVk", string(rv.Signature))
+
+		var bv base64Input
+		err = decoded.Unmarshal(r, &bv)
+		assertNoError(t, err)
+		assertEqual(t, "signed", string(bv.Signature))
+		assertEqual(t, string([]byte{0, 1, 255}), string(*bv.Digest))
+
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Signature", "not base64!")
+		err = decoded.Unmarshal(r, &base64Input{})
+		assertError(t, err)
+		assertContains(t, err.Error(), `header "X-Signature" (base64Input.Signature): decode base64`)
+
+		type badInput struct {
+			Sig []byte `header:"X-Signature,raw,base64"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+	})
+
+	t.Run("json maps with query params", func(t *testing.T) {
+		type input struct {
+			Metadata map[string]any    `json:"metadata"`
+			Labels   map[string]string `json:"labels"`
+			Name     string            `json:"name"`
+			Page     int               `query:"page"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		body := `{"name":"job","metadata":{"owner":"ops","retries":3,"tags":["a"]},"labels":{"env":"prod"}}`
+		r := httptest.NewRequest("POST", "/?page=2&Metadata=x&Labels=y", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "job", v.Name)
+		assertEqual(t, 2, v.Page)
+		assertEqual(t, any("ops"), v.Metadata["owner"])
+		assertEqual(t, any(float64(3)), v.Metadata["retries"])
+		assertEqual(t, 3, len(v.Metadata))
+		assertEqual(t, "prod", v.Labels["env"])
+		assertEqual(t, 1, len(v.Labels))
+	})
+
+	t.Run("unknown field collector", func(t *testing.T) {
+		type pair struct {
+			Key, Value string
+		}
+		type input struct {
+			ID     int                 `query:"id"`
+			Sort   []pair              `query:"sort"`
+			Filter map[string][]string `query:"filter."`
+		}
+
+		unknown := map[string]string{}
+		unmarshaler, err := httpio.NewUnmarshaler[input](
+			httpio.WithRawQueryScan(),
+			httpio.WithUnknownFieldCollector(func(key string, vals []string) {
+				unknown[key] = strings.Join(vals, ",")
+			}),
+		)
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?id=1&foo=bar&foo=baz&sort_by=name&sort.name=asc&filter.status=open", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.ID)
+		assertEqual(t, 2, len(unknown))
+		assertEqual(t, "bar,baz", unknown["foo"])
+		assertEqual(t, "name", unknown["sort_by"])
+	})
+
+	t.Run("complex numbers", func(t *testing.T) {
+		type input struct {
+			Z      complex128   `query:"z"`
+			Small  complex64    `query:"small"`
+			Ptr    *complex128  `query:"ptr"`
+			Points []complex128 `query:"p"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?"+url.Values{
+			"z":     {"3+4i"},
+			"small": {"1.5-2i"},
+			"ptr":   {"2i"},
+			"p":     {"1", "(0+1i)"},
+		}.Encode(), nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, complex(3, 4), v.Z)
+		assertEqual(t, complex64(complex(1.5, -2)), v.Small)
+		assertEqual(t, complex(0, 2), *v.Ptr)
+		assertEqual(t, "[(1+0i) (0+1i)]", fmt.Sprint(v.Points))
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?z=3+4j", nil), &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), `query parameter "z" (input.Z): parse complex`)
+	})
+
+	t.Run("bracket array suffix", func(t *testing.T) {
+		type input struct {
+			Tags []string `query:"tags"`
+			IDs  []int    `query:"id"`
+			Name string   `query:"name"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithBracketArraySuffix(), httpio.WithRawQueryScan())
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?tags[]=a&tags[]=b&id=1&id[]=2&id[]=3&name[]=john", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "a,b", strings.Join(v.Tags, ","))
+		assertEqual(t, "[1 2 3]", fmt.Sprint(v.IDs))
+		assertEqual(t, "john", v.Name)
+
+		plain, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		v = input{}
+		err = plain.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 0, len(v.Tags))
+		assertEqual(t, "[1]", fmt.Sprint(v.IDs))
+	})
+
+	t.Run("per field delimiter", func(t *testing.T) {
+		type name struct {
+			First string `query:"first"`
+			Last  string `query:"last"`
+		}
+		type zip struct {
+			Code string `query:"code"`
+		}
+		type address struct {
+			City string `query:"city"`
+			Zip  zip    `query:"zip,delim=_"`
+		}
+		type input struct {
+			Name    name    `query:"name,delim=-"`
+			Address address `query:"address"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		r := httptest.NewRequest("GET", "/?name-first=John&name-last=Doe&address.city=Paris&address.zip_code=75001&name.first=ignored", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, name{First: "John", Last: "Doe"}, v.Name)
+		assertEqual(t, address{City: "Paris", Zip: zip{Code: "75001"}}, v.Address)
+
+		type badInput struct {
+			ID int `query:"id,delim=-"`
+		}
+		_, err = httpio.NewUnmarshaler[badInput]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "delim modifier requires a nested struct")
+	})
+
+	t.Run("decode into reflect value", func(t *testing.T) {
+		type input struct {
+			ID   int    `query:"id"`
+			Name string `json:"name"`
+		}
+
+		typ := reflect.TypeFor[input]()
+		decoder, err := httpio.NewDecoder(typ)
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("POST", "/?id=7", strings.NewReader(`{"name":"john"}`))
+		r.Header.Set("Content-Type", "application/json")
+		v := reflect.New(typ).Elem()
+		err = decoder.DecodeInto(r, v)
+		assertNoError(t, err)
+		assertEqual(t, input{ID: 7, Name: "john"}, v.Interface().(input))
+
+		err = decoder.DecodeInto(r, reflect.ValueOf(input{}))
+		assertError(t, err)
+		assertContains(t, err.Error(), "addressable")
+
+		err = decoder.DecodeInto(r, reflect.New(reflect.TypeFor[struct{ ID int }]()).Elem())
+		assertError(t, err)
+
+		_, err = httpio.NewDecoder(typ, httpio.WithAfterBind(func(*input, *http.Request) error { return nil }))
+		assertError(t, err)
+
+		_, err = httpio.NewDecoder(reflect.TypeFor[int]())
+		assertError(t, err)
+	})
+
+	t.Run("json body skipped without body fields", func(t *testing.T) {
+		type queryOnly struct {
+			ID   int    `query:"id"`
+			Name string `header:"X-Name"`
+			Skip string `json:"-"`
+		}
+		type embedded struct {
+			Name string
+		}
+		type withEmbedded struct {
+			ID int `query:"id"`
+			embedded
+		}
+
+		newRequest := func(body string) *http.Request {
+			r := httptest.NewRequest("POST", "/?id=1", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			return r
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[queryOnly]()
+		assertNoError(t, err)
+
+		r := newRequest(`not json`)
+		var v queryOnly
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.ID)
+		body, err := io.ReadAll(r.Body)
+		assertNoError(t, err)
+		assertEqual(t, "not json", string(body))
+
+		promoted, err := httpio.NewUnmarshaler[withEmbedded]()
+		assertNoError(t, err)
+		var e withEmbedded
+		err = promoted.Unmarshal(newRequest(`{"Name":"john"}`), &e)
+		assertNoError(t, err)
+		assertEqual(t, "john", e.Name)
+	})
+
+	t.Run("whole form into url.Values", func(t *testing.T) {
+		type input struct {
+			Name string     `form:"name"`
+			Form url.Values `form:"*"`
+			Page int        `query:"page"`
+		}
+
+		r := httptest.NewRequest("POST", "/?page=2", strings.NewReader("name=bob&tags=a&tags=b"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		var v input
+		err := httpio.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "bob", v.Name)
+		assertEqual(t, "name=bob&tags=a&tags=b", v.Form.Encode())
+		assertEqual(t, 2, v.Page)
+
+		type bad struct {
+			Form map[string]string `form:"*"`
+		}
+		_, err = httpio.NewUnmarshaler[bad]()
+		assertError(t, err)
+		assertContains(t, err.Error(), `form:"*" requires map[string][]string type`)
+	})
+
+	t.Run("number signs", func(t *testing.T) {
+		type input struct {
+			Int     int     `query:"int"`
+			Uint    uint    `query:"uint"`
+			Float   float64 `query:"float"`
+			Balance uint    `query:"balance,plus"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?int=%2B100&uint=100&float=%2B1.5&balance=%2B100", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, input{Int: 100, Uint: 100, Float: 1.5, Balance: 100}, v)
+
+		r = httptest.NewRequest("GET", "/?int=-5&float=-5&balance=5", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, input{Int: -5, Float: -5, Balance: 5}, v)
+
+		r = httptest.NewRequest("GET", "/?int=-0&float=-0", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 0, v.Int)
+		assertEqual(t, true, v.Float == 0 && math.Signbit(v.Float))
+
+		for query, msg := range map[string]string{
+			"uint=-5":      `"-5": negative value for unsigned type uint`,
+			"uint=-0":      `"-0": negative value for unsigned type uint`,
+			"uint=%2B100":  `"+100": sign prefix for unsigned type uint, use plus modifier to allow it`,
+			"balance=-5":   `"-5": negative value for unsigned type uint`,
+			"balance=%2B-": `parse uint`,
+		} {
+			r = httptest.NewRequest("GET", "/?"+query, nil)
+			err = unmarshaler.Unmarshal(r, &v)
+			assertError(t, err)
+			assertContains(t, err.Error(), msg)
+		}
+
+		type bad struct {
+			Name string `query:"name,plus"`
+		}
+		_, err = httpio.NewUnmarshaler[bad]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "plus modifier requires number type")
+	})
+
+	t.Run("query array limit", func(t *testing.T) {
+		type input struct {
+			IDs  []int    `query:"id"`
+			Name string   `query:"name"`
+			Tags []string `header:"X-Tag"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithQueryArrayLimit(3))
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?id=1&id=2&id=3&name=a&name=b&name=c&name=d", nil)
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "1 2 3", fmt.Sprint(v.IDs[0], v.IDs[1], v.IDs[2]))
+		assertEqual(t, "a", v.Name)
+
+		r = httptest.NewRequest("GET", "/?"+strings.Repeat("id=1&", 1000), nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+		assertContains(t, err.Error(), `query parameter "id" (input.IDs): too many values: got 1000, limit is 3`)
+		assertEqual(t, 0, len(v.IDs))
+
+		r = httptest.NewRequest("GET", "/", nil)
+		for range 4 {
+			r.Header.Add("X-Tag", "a")
+		}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+
+		type csvInput struct {
+			IDs []int `query:"ids,csv"`
+		}
+		csvUnmarshaler, err := httpio.NewUnmarshaler[csvInput](httpio.WithQueryArrayLimit(3))
+		assertNoError(t, err)
+
+		var c csvInput
+		err = csvUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/?ids=1,2&ids=3", nil), &c)
+		assertNoError(t, err)
+		assertEqual(t, 3, len(c.IDs))
+
+		c = csvInput{}
+		err = csvUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/?ids="+strings.TrimSuffix(strings.Repeat("1,", 10000), ","), nil), &c)
+		assertError(t, err)
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+		assertContains(t, err.Error(), "got 10000, limit is 3")
+		assertEqual(t, 0, len(c.IDs))
+	})
+
+	t.Run("query array limit for map, pair and struct slice fields", func(t *testing.T) {
+		type pair struct {
+			Key, Value string
+		}
+		type input struct {
+			All    url.Values          `query:"*"`
+			Filter map[string][]string `query:"filter."`
+			Sort   []pair              `query:"sort"`
+			Custom http.Header         `header:"X-Custom-*"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithQueryArrayLimit(3))
+		assertNoError(t, err)
+
+		decode := func(query string) (input, error) {
+			var v input
+			err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?"+query, nil), &v)
+			return v, err
+		}
+
+		v, err := decode("a=1&a=2&a=3&filter.s=x&filter.s=y&sort.name=asc&sort.id=desc&sort.name=desc")
+		assertNoError(t, err)
+		assertEqual(t, 3, len(v.All["a"]))
+		assertEqual(t, 2, len(v.Filter["s"]))
+		assertEqual(t, 3, len(v.Sort))
+
+		v, err = decode(strings.Repeat("a=1&", 4))
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+		assertContains(t, err.Error(), `query parameter "a" (input.All): too many values: got 4, limit is 3`)
+		assertEqual(t, 0, len(v.All))
+
+		_, err = decode(strings.Repeat("filter.s=x&", 4))
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+		assertContains(t, err.Error(), `query parameter "filter.s" (input.Filter)`)
+
+		type pairInput struct {
+			Sort []pair `query:"sort"`
+		}
+		pairUnmarshaler, err := httpio.NewUnmarshaler[pairInput](httpio.WithQueryArrayLimit(3))
+		assertNoError(t, err)
+
+		var p pairInput
+		err = pairUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/?"+strings.Repeat("sort.name=asc&", 4), nil), &p)
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+		assertContains(t, err.Error(), `query parameter "sort.name" (pairInput.Sort): too many values: got 4, limit is 3`)
+		assertEqual(t, 0, len(p.Sort))
+
+		r := httptest.NewRequest("GET", "/", nil)
+		for range 4 {
+			r.Header.Add("X-Custom-A", "a")
+		}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+		assertContains(t, err.Error(), `header "X-Custom-A" (input.Custom)`)
+
+		type item struct {
+			Name string `query:"name"`
+		}
+		type withItems struct {
+			Items []item `query:"items"`
+		}
+		itemsUnmarshaler, err := httpio.NewUnmarshaler[withItems](httpio.WithSliceStructSupport(), httpio.WithQueryArrayLimit(3))
+		assertNoError(t, err)
+
+		var w withItems
+		err = itemsUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/?items[0].name=a&items[1].name=b&items[2].name=c", nil), &w)
+		assertNoError(t, err)
+		assertEqual(t, 3, len(w.Items))
+
+		w = withItems{}
+		err = itemsUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/?items[0].name=a&items[1].name=b&items[2].name=c&items[3].name=d", nil), &w)
+		assertEqual(t, true, errors.Is(err, httpio.ErrTooManyValues))
+		assertContains(t, err.Error(), `query parameter "items" (withItems.Items): too many values: got 4, limit is 3`)
+		assertEqual(t, 0, len(w.Items))
+	})
+
+	t.Run("sql null time", func(t *testing.T) {
+		type input struct {
+			Since  sql.NullTime         `query:"since"`
+			Day    sql.NullTime         `query:"day,layout=2006-01-02"`
+			Until  sql.Null[time.Time]  `query:"until,layout=2006-01-02"`
+			At     time.Time            `header:"X-At,layout=15:04"`
+			Score  sql.NullFloat64      `query:"score"`
+			Active sql.NullBool         `query:"active"`
+			Days   []sql.NullTime       `query:"days,layout=2006-01-02"`
+			Opt    *sql.Null[time.Time] `query:"opt"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		var v input
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, false, v.Since.Valid)
+		assertEqual(t, false, v.Day.Valid)
+		assertEqual(t, false, v.Until.Valid)
+		assertEqual(t, false, v.Score.Valid)
+		assertEqual(t, false, v.Active.Valid)
+		assertEqual(t, (*sql.Null[time.Time])(nil), v.Opt)
+
+		r := httptest.NewRequest("GET", "/?since=2024-05-01T10:00:00Z&day=2024-05-02&until=2024-05-03&score=0&active=false&days=2024-05-04&opt=2024-05-05T00:00:00Z", nil)
+		r.Header.Set("X-At", "13:45")
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, sql.NullTime{Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Valid: true}, v.Since)
+		assertEqual(t, sql.NullTime{Time: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), Valid: true}, v.Day)
+		assertEqual(t, sql.Null[time.Time]{V: time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), Valid: true}, v.Until)
+		assertEqual(t, time.Date(0, 1, 1, 13, 45, 0, 0, time.UTC), v.At)
+		assertEqual(t, sql.NullFloat64{Float64: 0, Valid: true}, v.Score)
+		assertEqual(t, sql.NullBool{Bool: false, Valid: true}, v.Active)
+		assertEqual(t, 1, len(v.Days))
+		assertEqual(t, sql.NullTime{Time: time.Date(2024, 5, 4, 0, 0, 0, 0, time.UTC), Valid: true}, v.Days[0])
+		assertEqual(t, time.Date(2024, 5, 5, 0, 0, 0, 0, time.UTC), v.Opt.V)
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?day=05/02/2024", nil), &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "parse time")
+
+		type bad struct {
+			Day string `query:"day,layout=2006-01-02"`
+		}
+		_, err = httpio.NewUnmarshaler[bad]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "layout modifier requires time.Time")
+	})
+
+	t.Run("decode error details", func(t *testing.T) {
+		type input struct {
+			Age   int    `query:"age"`
+			Token string `header:"X-Token,required"`
+		}
+
+		r := httptest.NewRequest("POST", "/users?age=abc", nil)
+
+		plain, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		var v input
+		plainErr := plain.Unmarshal(r, &v)
+		assertError(t, plainErr)
+		var decodeErr *httpio.DecodeError
+		assertEqual(t, false, errors.As(plainErr, &decodeErr))
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithDecodeErrorDetails(), httpio.WithBestEffort())
+		assertNoError(t, err)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		assertEqual(t, plainErr.Error(), strings.Split(err.Error(), "\n")[0])
+
+		assertEqual(t, true, errors.As(err, &decodeErr))
+		assertEqual(t, "POST", decodeErr.Method)
+		assertEqual(t, "/users", decodeErr.Path)
+		assertEqual(t, httpio.SourceQuery, decodeErr.Source)
+		assertEqual(t, "age", decodeErr.Param)
+		assertEqual(t, "abc", decodeErr.Value)
+		assertEqual(t, true, errors.Is(err, strconv.ErrSyntax))
+
+		var missing *httpio.DecodeError
+		for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+			if errors.Is(e, httpio.ErrMissingRequired) {
+				assertEqual(t, true, errors.As(e, &missing))
+			}
+		}
+		assertEqual(t, httpio.SourceHeader, missing.Source)
+		assertEqual(t, "X-Token", missing.Param)
+		assertEqual(t, "", missing.Value)
+
+		type item struct {
+			Name string `query:"name,required"`
+			Qty  int    `query:"qty"`
+		}
+		type withItems struct {
+			Items []item `query:"items"`
+		}
+		slicesUnmarshaler, err := httpio.NewUnmarshaler[withItems](httpio.WithDecodeErrorDetails(), httpio.WithSliceStructSupport())
+		assertNoError(t, err)
+		var sv withItems
+		err = slicesUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/?items[0].name=a&items[0].qty=x", nil), &sv)
+		assertEqual(t, true, errors.As(err, &decodeErr))
+		assertEqual(t, "items[0].qty", decodeErr.Param)
+		assertEqual(t, "x", decodeErr.Value)
+
+		err = slicesUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/?items[0].qty=1", nil), &sv)
+		assertEqual(t, true, errors.As(err, &decodeErr))
+		assertEqual(t, true, errors.Is(decodeErr, httpio.ErrMissingRequired))
+		assertEqual(t, "items[0].name", decodeErr.Param)
+
+		type fromContext struct {
+			UserID int `ctx:"user_id"`
+		}
+		ctxUnmarshaler, err := httpio.NewUnmarshaler[fromContext](httpio.WithDecodeErrorDetails(), httpio.WithContextKey("user_id", ctxKey("user_id")))
+		assertNoError(t, err)
+		var cv fromContext
+		ctx := context.WithValue(context.Background(), ctxKey("user_id"), 4.2)
+		err = ctxUnmarshaler.Unmarshal(httptest.NewRequest("GET", "/", nil).WithContext(ctx), &cv)
+		assertEqual(t, true, errors.As(err, &decodeErr))
+		assertEqual(t, httpio.SourceCtx, decodeErr.Source)
+		assertEqual(t, "user_id", decodeErr.Param)
+		assertContains(t, decodeErr.Error(), "value of type float64 is not assignable to int")
+	})
+
+	t.Run("nil pointer struct with tagged subfields", func(t *testing.T) {
+		type AppConfig struct {
+			Mode  string `query:"mode"`
+			Level int    `query:"level"`
+			Token string `header:"Authorization,bearer"`
+			Theme string `json:"theme"`
+		}
+		type input struct {
+			Name   string     `json:"name"`
+			Config *AppConfig `json:"app_config"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		newRequest := func(target string) *http.Request {
+			r := httptest.NewRequest("POST", target, strings.NewReader(`{"name":"a"}`))
+			r.Header.Set("Content-Type", "application/json")
+			return r
+		}
+
+		var v input
+		err = unmarshaler.Unmarshal(newRequest("/?Config.mode=dark"), &v)
+		assertNoError(t, err)
+		assertEqual(t, "a", v.Name)
+		assertEqual(t, AppConfig{Mode: "dark"}, *v.Config)
+
+		v = input{}
+		r := newRequest("/")
+		r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, (*AppConfig)(nil), v.Config)
+
+		v = input{}
+		err = unmarshaler.Unmarshal(newRequest("/?Config.level=abc"), &v)
+		assertError(t, err)
+		assertEqual(t, (*AppConfig)(nil), v.Config)
+	})
+
+	t.Run("unix time", func(t *testing.T) {
+		type input struct {
+			TS     time.Time    `query:"ts,unix"`
+			Millis time.Time    `query:"ms,unixmilli"`
+			Since  sql.NullTime `query:"since,unix"`
+			At     *time.Time   `header:"X-At,unixmilli"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?ts=1700000000&ms=1700000000123&since=0", nil)
+		r.Header.Set("X-At", "-1500")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), v.TS)
+		assertEqual(t, time.Date(2023, 11, 14, 22, 13, 20, 123e6, time.UTC), v.Millis)
+		assertEqual(t, sql.NullTime{Time: time.Unix(0, 0).UTC(), Valid: true}, v.Since)
+		assertEqual(t, time.Date(1969, 12, 31, 23, 59, 58, 500e6, time.UTC), *v.At)
+
+		r = httptest.NewRequest("GET", "/?ts=-86400&ms=0", nil)
+		v = input{}
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC), v.TS)
+		assertEqual(t, time.Unix(0, 0).UTC(), v.Millis)
+		assertEqual(t, false, v.Since.Valid)
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?ts=2024-01-01", nil), &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "parse unix time")
+
+		type bad struct {
+			TS int64 `query:"ts,unix"`
+		}
+		_, err = httpio.NewUnmarshaler[bad]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "unix and unixmilli modifiers require time.Time")
+
+		type both struct {
+			TS time.Time `query:"ts,unix,layout=2006"`
+		}
+		_, err = httpio.NewUnmarshaler[both]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "layout modifier can't be combined with unix and unixmilli modifiers")
+	})
+
+	t.Run("method override", func(t *testing.T) {
+		type input struct {
+			Method string `meta:"method"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithAllowMethodOverride())
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("POST", "/", nil)
+		r.Header.Set("X-HTTP-Method-Override", "patch")
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "PATCH", v.Method)
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("POST", "/", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, "POST", v.Method)
+
+		r = httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-HTTP-Method-Override", "DELETE")
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "GET", v.Method)
+
+		r = httptest.NewRequest("POST", "/", nil)
+		r.Header.Set("X-HTTP-Method-Override", "PUT")
+		err = httpio.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "POST", v.Method)
+	})
+
+	t.Run("json modifier", func(t *testing.T) {
+		type filter struct {
+			Age  int    `json:"age"`
+			Name string `json:"name"`
+		}
+		type input struct {
+			IDs    []int             `query:"ids,json"`
+			Filter filter            `query:"filter,json"`
+			Labels map[string]string `query:"labels,json"`
+			Sort   *filter           `header:"X-Sort,json"`
+			Page   int               `query:"page"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		q := url.Values{}
+		q.Set("ids", "[1,2,3]")
+		q.Set("filter", `{"age":30,"name":"john"}`)
+		q.Set("labels", `{"env":"prod"}`)
+		q.Set("page", "2")
+		r := httptest.NewRequest("GET", "/?"+q.Encode(), nil)
+		r.Header.Set("X-Sort", `{"name":"asc"}`)
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "[1 2 3]", fmt.Sprint(v.IDs))
+		assertEqual(t, filter{Age: 30, Name: "john"}, v.Filter)
+		assertEqual(t, "prod", v.Labels["env"])
+		assertEqual(t, filter{Name: "asc"}, *v.Sort)
+		assertEqual(t, 2, v.Page)
+
+		v = input{}
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?ids=%5B1,%22a%22%5D", nil), &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), "decode json")
+		assertEqual(t, 0, len(v.IDs))
+		assertEqual(t, (*filter)(nil), v.Sort)
+
+		type bad struct {
+			IDs []int `query:"ids,json,csv"`
+		}
+		_, err = httpio.NewUnmarshaler[bad]()
+		assertError(t, err)
+		assertContains(t, err.Error(), "json modifier can't be combined")
+	})
+
+	t.Run("client ip", func(t *testing.T) {
+		type input struct {
+			ClientIP netip.Addr `meta:"client_ip"`
+		}
+
+		direct, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+		oneProxy, err := httpio.NewUnmarshaler[input](httpio.WithTrustedProxies(1))
+		assertNoError(t, err)
+		twoProxies, err := httpio.NewUnmarshaler[input](httpio.WithTrustedProxies(2))
+		assertNoError(t, err)
+
+		for _, tc := range []struct {
+			name        string
+			unmarshaler *httpio.Unmarshaler[input]
+			remoteAddr  string
+			xff         []string
+			realIP      string
+			expected    string
+		}{
+			{name: "direct", unmarshaler: direct, remoteAddr: "203.0.113.7:5555", expected: "203.0.113.7"},
+			{name: "direct ignores headers", unmarshaler: direct, remoteAddr: "203.0.113.7:5555", xff: []string{"1.1.1.1"}, realIP: "2.2.2.2", expected: "203.0.113.7"},
+			{name: "direct ipv6", unmarshaler: direct, remoteAddr: "[2001:db8::1]:443", expected: "2001:db8::1"},
+			{name: "single xff", unmarshaler: oneProxy, remoteAddr: "10.0.0.1:5555", xff: []string{"203.0.113.7"}, expected: "203.0.113.7"},
+			{name: "spoofed xff", unmarshaler: oneProxy, remoteAddr: "10.0.0.1:5555", xff: []string{"1.1.1.1, 203.0.113.7"}, expected: "203.0.113.7"},
+			{name: "chained xff", unmarshaler: twoProxies, remoteAddr: "10.0.0.2:5555", xff: []string{"1.1.1.1, 203.0.113.7", "10.0.0.1"}, expected: "203.0.113.7"},
+			{name: "short xff", unmarshaler: twoProxies, remoteAddr: "10.0.0.2:5555", xff: []string{"203.0.113.7:1234"}, expected: "203.0.113.7"},
+			{name: "real ip", unmarshaler: oneProxy, remoteAddr: "10.0.0.1:5555", realIP: "203.0.113.7", expected: "203.0.113.7"},
+			{name: "no headers", unmarshaler: oneProxy, remoteAddr: "10.0.0.1:5555", expected: "10.0.0.1"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				r := httptest.NewRequest("GET", "/", nil)
+				r.RemoteAddr = tc.remoteAddr
+				for _, v := range tc.xff {
+					r.Header.Add("X-Forwarded-For", v)
+				}
+				if tc.realIP != "" {
+					r.Header.Set("X-Real-IP", tc.realIP)
+				}
+
+				var v input
+				err := tc.unmarshaler.Unmarshal(r, &v)
+				assertNoError(t, err)
+				assertEqual(t, netip.MustParseAddr(tc.expected), v.ClientIP)
+			})
+		}
+	})
+
+	t.Run("non-canonical header map", func(t *testing.T) {
+		type input struct {
+			Token  string              `header:"X-Token"`
+			Tags   []string            `header:"X-Tag"`
+			Custom map[string][]string `header:"X-Custom-*"`
+		}
+
+		r := httptest.NewRequest("GET", "/", nil)
+		// as delivered by HTTP/2 transports that don't canonicalize names
+		r.Header = http.Header{
+			"x-token":    {"abc"},
+			"X-Tag":      {"a"},
+			"x-tag":      {"b"},
+			"X-TAG":      {"c"},
+			"x-custom-a": {"1"},
+		}
+
+		var v input
+		err := httpio.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "abc", v.Token)
+		assertEqual(t, "a", strings.Join(v.Tags, " "))
+		assertEqual(t, "1", strings.Join(v.Custom["X-Custom-A"], " "))
+
+		merging, err := httpio.NewUnmarshaler[input](httpio.WithHeaderCaseMerging())
+		assertNoError(t, err)
+		v = input{}
+		err = merging.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "abc", v.Token)
+		assertEqual(t, "a c b", strings.Join(v.Tags, " "))
+		assertEqual(t, "1", strings.Join(v.Custom["X-Custom-A"], " "))
+		assertEqual(t, "a", strings.Join(r.Header["X-Tag"], " "))
+
+		exact, err := httpio.NewUnmarshaler[input](httpio.WithHeaderCanonicalization(false))
+		assertNoError(t, err)
+		v = input{}
+		err = exact.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, "", v.Token)
+		assertEqual(t, "a", strings.Join(v.Tags, " "))
+	})
+
+	t.Run("required params", func(t *testing.T) {
+		type input struct {
+			Age   int    `query:"age,required"`
+			Token string `header:"X-Token,required"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?age=30", nil)
+		r.Header.Set("X-Token", "secret")
+
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, 30, v.Age)
+		assertEqual(t, "secret", v.Token)
+
+		r = httptest.NewRequest("GET", "/?age=30", nil)
+		err = unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertContains(t, err.Error(), "X-Token")
+	})
+
+	t.Run("missing cookies are absent", func(t *testing.T) {
+		type input struct {
+			Session *string `cookie:"session"`
+			Theme   string  `cookie:"theme,default=light"`
+			Token   string  `cookie:"token,required"`
+			Locale  string  `from:"cookie=locale;query=locale"`
+		}
+
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(t, err)
+
+		r := httptest.NewRequest("GET", "/?locale=en", nil)
+		r.AddCookie(&http.Cookie{Name: "token", Value: "abc"})
+
+		// missing cookies used to fail with "cookie session is invalid: http: named cookie not present"
+		var v input
+		err = unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+		assertEqual(t, (*string)(nil), v.Session)
+		assertEqual(t, "light", v.Theme)
+		assertEqual(t, "abc", v.Token)
+		assertEqual(t, "en", v.Locale)
+
+		err = unmarshaler.Unmarshal(httptest.NewRequest("GET", "/", nil), &v)
+		if !errors.Is(err, httpio.ErrMissingRequired) {
+			t.Fatalf("expected ErrMissingRequired, got %v", err)
+		}
+		assertEqual(t, false, errors.Is(err, http.ErrNoCookie))
+		assertContains(t, err.Error(), `cookie "token"`)
+	})
+}
+
+type event interface {
+	eventType() string
+}
+
+type clickEvent struct {
+	Type string `json:"type"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+func (*clickEvent) eventType() string { return "click" }
+
+type keyEvent struct {
+	Type string `json:"type"`
+	Key  string `json:"key"`
+}
+
+func (*keyEvent) eventType() string { return "key" }
+
+func TestDiscriminator(t *testing.T) {
+	type input struct {
+		Source string `header:"X-Source"`
+		Event  event  `json:"event"`
+	}
+
+	unmarshaler, err := httpio.NewUnmarshaler[input](httpio.WithDiscriminator("type", map[string]reflect.Type{
+		"click": reflect.TypeFor[clickEvent](),
+		"key":   reflect.TypeFor[keyEvent](),
+	}))
+	assertNoError(t, err)
+
+	t.Run("click event", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"event":{"type":"click","x":10,"y":20}}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("X-Source", "web")
+
+		var v input
+		err := unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, "web", v.Source)
+		click, ok := v.Event.(*clickEvent)
+		if !ok {
+			t.Fatalf("expected *clickEvent, got %T", v.Event)
+		}
+		assertEqual(t, clickEvent{Type: "click", X: 10, Y: 20}, *click)
+	})
+
+	t.Run("key event", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"event":{"type":"key","key":"Enter"}}`))
+		r.Header.Set("Content-Type", "application/json")
+
+		var v input
+		err := unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		key, ok := v.Event.(*keyEvent)
+		if !ok {
+			t.Fatalf("expected *keyEvent, got %T", v.Event)
+		}
+		assertEqual(t, "Enter", key.Key)
+	})
+
+	t.Run("unknown discriminator value", func(t *testing.T) {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(`{"event":{"type":"scroll"}}`))
+		r.Header.Set("Content-Type", "application/json")
+
+		var v input
+		err := unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+	})
+
+	t.Run("mapped type does not implement interface", func(t *testing.T) {
+		_, err := httpio.NewUnmarshaler[input](httpio.WithDiscriminator("type", map[string]reflect.Type{
+			"other": reflect.TypeFor[struct{}](),
+		}))
+		assertError(t, err)
+	})
+}
+
+func TestFields(t *testing.T) {
+	type pagination struct {
+		Limit  int `query:"limit"`
+		Offset int `query:"offset,required"`
+	}
+	type input struct {
+		Page      pagination `query:"page"`
+		Sort      *string    `query:"sort"`
+		UserID    string     `path:"user_id,required"`
+		Token     string     `header:"x-token"`
+		SessionID string     `cookie:"session_id"`
+		Title     string     `form:"title"`
+	}
+
+	unmarshaler, err := httpio.NewUnmarshaler[input]()
+	assertNoError(t, err)
+
+	fields := unmarshaler.Fields()
+
+	expected := []httpio.FieldInfo{
+		{Source: httpio.SourceQuery, Name: "page.limit", StructField: "pagination.Limit", Type: reflect.TypeFor[int]()},
+		{Source: httpio.SourceQuery, Name: "page.offset", StructField: "pagination.Offset", Type: reflect.TypeFor[int](), Required: true},
+		{Source: httpio.SourceQuery, Name: "sort", StructField: "input.Sort", Type: reflect.TypeFor[*string](), Pointer: true},
+		{Source: httpio.SourceForm, Name: "title", StructField: "input.Title", Type: reflect.TypeFor[string]()},
+		{Source: httpio.SourcePath, Name: "user_id", StructField: "input.UserID", Type: reflect.TypeFor[string](), Required: true},
+		{Source: httpio.SourceHeader, Name: "X-Token", StructField: "input.Token", Type: reflect.TypeFor[string]()},
+		{Source: httpio.SourceCookie, Name: "session_id", StructField: "input.SessionID", Type: reflect.TypeFor[string]()},
+	}
+	assertEqual(t, len(expected), len(fields))
+	for i := range expected {
+		assertEqual(t, expected[i], fields[i])
+	}
+}
+
+type ctxKey string
+
+type ctxUser struct {
+	ID   int
+	Name string
+}
+
+type ctxRequestID string
+
+func (id ctxRequestID) String() string { return string(id) }
+
+func TestContextSource(t *testing.T) {
+	type input struct {
+		UserID    int      `ctx:"user_id"`
+		User      *ctxUser `ctx:"user"`
+		RequestID string   `ctx:"request_id"`
+		Tenant    *string  `ctx:"tenant"`
+	}
+
+	unmarshaler, err := httpio.NewUnmarshaler[input](
+		httpio.WithContextKey("user_id", ctxKey("user_id")),
+		httpio.WithContextKey("user", ctxKey("user")),
+		httpio.WithContextKey("request_id", ctxKey("request_id")),
+		httpio.WithContextKey("tenant", ctxKey("tenant")),
+	)
+	assertNoError(t, err)
+
+	t.Run("values present", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxKey("user_id"), "42")
+		ctx = context.WithValue(ctx, ctxKey("user"), &ctxUser{ID: 42, Name: "john"})
+		ctx = context.WithValue(ctx, ctxKey("request_id"), ctxRequestID("req-1"))
+		ctx = context.WithValue(ctx, ctxKey("tenant"), "acme")
+		r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+
+		var v input
+		err := unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, 42, v.UserID)
+		assertEqual(t, ctxUser{ID: 42, Name: "john"}, *v.User)
+		assertEqual(t, "req-1", v.RequestID)
+		assertEqual(t, "acme", *v.Tenant)
+	})
+
+	t.Run("values absent", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+
+		var v input
+		err := unmarshaler.Unmarshal(r, &v)
+		assertNoError(t, err)
+
+		assertEqual(t, 0, v.UserID)
+		assertEqual(t, (*ctxUser)(nil), v.User)
+		assertEqual(t, (*string)(nil), v.Tenant)
+	})
+
+	t.Run("value of unsupported type", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ctxKey("user_id"), 4.2)
+		r := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+
+		var v input
+		err := unmarshaler.Unmarshal(r, &v)
+		assertError(t, err)
+	})
+
+	t.Run("missing context key registration", func(t *testing.T) {
+		_, err := httpio.NewUnmarshaler[input](httpio.WithContextKey("user_id", ctxKey("user_id")))
+		assertError(t, err)
+	})
+}
+
+type dateRange struct {
+	Start int `query:"start"`
+	End   int `query:"end"`
+}
+
+var errInvalidRange = errors.New("start must not be after end")
+
+func (d *dateRange) Validate() error {
+	if d.Start > d.End {
+		return errInvalidRange
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	unmarshaler, err := httpio.NewUnmarshaler[dateRange]()
+	assertNoError(t, err)
+
+	t.Run("valid", func(t *testing.T) {
+		var v dateRange
+		err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?start=1&end=2", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, 1, v.Start)
+		assertEqual(t, 2, v.End)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		var v dateRange
+		err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?start=3&end=2", nil), &v)
+		if !errors.Is(err, errInvalidRange) {
+			t.Fatalf("expected errInvalidRange, got %v", err)
+		}
+	})
+
+	t.Run("not called on decode error", func(t *testing.T) {
+		var v dateRange
+		err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?start=x&end=2", nil), &v)
+		assertError(t, err)
+		if errors.Is(err, errInvalidRange) {
+			t.Fatalf("unexpected validation error: %v", err)
+		}
+	})
+}
+
+var errBadSemver = errors.New("bad semver")
+
+type semver struct {
+	Major, Minor int
+}
+
+func (v *semver) UnmarshalText(b []byte) error {
+	if _, err := fmt.Sscanf(string(b), "%d.%d", &v.Major, &v.Minor); err != nil {
+		return errBadSemver
+	}
+	return nil
+}
+
+type color int
+
+const (
+	colorRed color = iota + 1
+	colorGreen
+)
+
+func (c color) String() string {
+	switch c {
+	case colorRed:
+		return "red"
+	case colorGreen:
+		return "green"
+	}
+	return "unknown"
+}
+
+func parseColor(s string) (color, error) {
+	switch s {
+	case "red":
+		return colorRed, nil
+	case "green":
+		return colorGreen, nil
+	}
+	return 0, fmt.Errorf("unknown color %q", s)
+}
+
+func TestRegisterEnum(t *testing.T) {
+	httpio.RegisterEnum(parseColor)
+
+	type input struct {
+		Color    color   `query:"color"`
+		Fallback *color  `query:"fallback"`
+		Palette  []color `query:"palette,csv"`
+		Primary  color   `query:"primary,oneof=red"`
+	}
+
+	unmarshaler, err := httpio.NewUnmarshaler[input]()
+	assertNoError(t, err)
+
+	t.Run("known values", func(t *testing.T) {
+		var v input
+		err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?color=green&fallback=red&palette=red,green&primary=red", nil), &v)
+		assertNoError(t, err)
+		assertEqual(t, colorGreen, v.Color)
+		assertEqual(t, colorRed, *v.Fallback)
+		assertEqual(t, 2, len(v.Palette))
+		assertEqual(t, colorGreen, v.Palette[1])
+		assertEqual(t, colorRed, v.Primary)
+	})
+
+	t.Run("unknown value", func(t *testing.T) {
+		var v input
+		err := unmarshaler.Unmarshal(httptest.NewRequest("GET", "/?palette=red,blue", nil), &v)
+		assertError(t, err)
+		assertContains(t, err.Error(), `unknown color "blue"`)
+	})
 }
 
 func BenchmarkUnmarshal(b *testing.B) {
@@ -316,6 +4349,156 @@ func BenchmarkUnmarshal(b *testing.B) {
 	}
 }
 
+// BenchmarkUnmarshalHeaders tracks allocations of header-only structs:
+// scalar fields decode without allocations when dst is reused,
+// slice fields allocate only their backing array.
+func BenchmarkUnmarshalHeaders(b *testing.B) {
+	type input struct {
+		RequestID string   `header:"X-Request-Id"`
+		Accept    []string `header:"Accept"`
+	}
+	type scalars struct {
+		RequestID string `header:"X-Request-Id"`
+		Retries   int    `header:"X-Retries"`
+		Debug     bool   `header:"X-Debug"`
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	for i := range 30 {
+		r.Header.Set("X-Filler-"+strconv.Itoa(i), "value")
+	}
+	r.Header.Set("X-Request-Id", "abc")
+	r.Header.Set("X-Retries", "3")
+	r.Header.Set("X-Debug", "true")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+
+	b.Run("with slice", func(b *testing.B) {
+		unmarshaler, err := httpio.NewUnmarshaler[input]()
+		assertNoError(b, err)
+
+		b.ReportAllocs()
+		for b.Loop() {
+			var v input
+			err := unmarshaler.Unmarshal(r, &v)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("scalars", func(b *testing.B) {
+		unmarshaler, err := httpio.NewUnmarshaler[scalars]()
+		assertNoError(b, err)
+
+		b.ReportAllocs()
+		var v scalars
+		for b.Loop() {
+			err := unmarshaler.Unmarshal(r, &v)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUnmarshalQuery(b *testing.B) {
+	type input struct {
+		ID     int      `query:"id"`
+		Name   string   `query:"name"`
+		Banned bool     `query:"banned"`
+		Income uint     `query:"income"`
+		Tags   []string `query:"tag"`
+	}
+
+	r := httptest.NewRequest("GET", "/?id=1&name=John+Doe&banned=true&income=100000&tag=a&tag=b&other=x", nil)
+
+	for _, bc := range []struct {
+		name string
+		opts []httpio.UnmarshalerOption
+	}{
+		{name: "url.Values"},
+		{name: "raw scan", opts: []httpio.UnmarshalerOption{httpio.WithRawQueryScan()}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			unmarshaler, err := httpio.NewUnmarshaler[input](bc.opts...)
+			assertNoError(b, err)
+
+			b.ReportAllocs()
+
+			for b.Loop() {
+				var v input
+				err := unmarshaler.Unmarshal(r, &v)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPackageUnmarshal(b *testing.B) {
+	type input struct {
+		ID     int      `query:"id"`
+		Name   string   `query:"name"`
+		Tags   []string `query:"tag"`
+		Token  string   `header:"X-Token"`
+		Banned bool     `query:"banned"`
+	}
+
+	r := httptest.NewRequest("GET", "/?id=1&name=john&tag=a&tag=b&banned=true", nil)
+	r.Header.Set("X-Token", "secret")
+
+	b.Run("new unmarshaler per call", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			unmarshaler, err := httpio.NewUnmarshaler[input]()
+			if err != nil {
+				b.Fatal(err)
+			}
+			var v input
+			if err := unmarshaler.Unmarshal(r, &v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var v input
+			if err := httpio.Unmarshal(r, &v); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkUnmarshalQueryOnlyJSON decodes a query-only struct sent with JSON content type,
+// the body is left unread as no field can receive it.
+func BenchmarkUnmarshalQueryOnlyJSON(b *testing.B) {
+	type input struct {
+		ID   int    `query:"id"`
+		Name string `query:"name"`
+	}
+
+	unmarshaler, err := httpio.NewUnmarshaler[input]()
+	assertNoError(b, err)
+
+	body := []byte(`{"id":1,"name":"john","tags":["a","b","c"],"meta":{"k":"v"}}`)
+	r := httptest.NewRequest("POST", "/?id=1&name=john", nil)
+	r.Header.Set("Content-Type", "application/json")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		var v input
+		if err := unmarshaler.Unmarshal(r, &v); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func assertEqual[T comparable](tb testing.TB, expected, got T) {
 	tb.Helper()
 	if expected != got {
@@ -323,6 +4506,13 @@ func assertEqual[T comparable](tb testing.TB, expected, got T) {
 	}
 }
 
+func assertContains(tb testing.TB, s, substr string) {
+	tb.Helper()
+	if !strings.Contains(s, substr) {
+		tb.Fatalf("expected %q to contain %q", s, substr)
+	}
+}
+
 func assertNoError(tb testing.TB, err error) {
 	tb.Helper()
 	if err != nil {
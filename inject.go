@@ -0,0 +1,85 @@
+package httpio
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+// injector extracts request metadata for `inject:"name"` fields.
+type injector struct {
+	typ reflect.Type
+	get func(r *http.Request) (any, bool)
+}
+
+var injectors = map[string]injector{
+	"media_type": {
+		typ: reflect.TypeFor[string](),
+		get: func(r *http.Request) (any, bool) {
+			mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			return mt, err == nil
+		},
+	},
+	"media_params": {
+		typ: reflect.TypeFor[map[string]string](),
+		get: func(r *http.Request) (any, bool) {
+			_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			return params, err == nil
+		},
+	},
+	"proto_major": {
+		typ: reflect.TypeFor[int](),
+		get: func(r *http.Request) (any, bool) {
+			return r.ProtoMajor, r.ProtoMajor > 0
+		},
+	},
+	"deadline": {
+		typ: reflect.TypeFor[time.Time](),
+		get: func(r *http.Request) (any, bool) {
+			return r.Context().Deadline()
+		},
+	},
+	"is_http2": {
+		typ: reflect.TypeFor[bool](),
+		get: func(r *http.Request) (any, bool) {
+			return r.ProtoMajor == 2, true
+		},
+	},
+}
+
+// checkInjectField verifies that injector exists and its value fits into ft or *ft.
+func checkInjectField(name string, ft reflect.Type) error {
+	inj, ok := injectors[name]
+	if !ok {
+		return fmt.Errorf("unknown inject name %q", name)
+	}
+	if ft.Kind() == reflect.Pointer {
+		ft = ft.Elem()
+	}
+	if !inj.typ.AssignableTo(ft) {
+		return fmt.Errorf("inject %q requires %v field, got %v", name, inj.typ, ft)
+	}
+	return nil
+}
+
+func unmarshalInject(
+	r *http.Request,
+	fields map[string]compiledField,
+	st *decodeState,
+) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	for name, cf := range fields {
+		v, ok := injectors[name].get(r)
+		if !ok {
+			continue
+		}
+		st.assign(cf, reflect.ValueOf(v))
+	}
+
+	return nil
+}
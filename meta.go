@@ -0,0 +1,108 @@
+package httpio
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// methodOverrideHeader carries the method tunneled through POST, see WithAllowMethodOverride.
+const methodOverrideHeader = "X-HTTP-Method-Override"
+
+// metaOptions are Unmarshaler options affecting meta values.
+type metaOptions struct {
+	// methodOverride is set by WithAllowMethodOverride
+	methodOverride bool
+	// trustedProxies is set by WithTrustedProxies
+	trustedProxies int
+}
+
+// metaValues are values of `meta:"key"` fields taken from *http.Request itself.
+// They go through the regular setters, so e.g. remote_addr can be bound into netip.AddrPort.
+var metaValues = map[string]func(r *http.Request, o metaOptions) (string, bool){
+	"remote_addr": func(r *http.Request, _ metaOptions) (string, bool) {
+		return r.RemoteAddr, r.RemoteAddr != ""
+	},
+	"method": func(r *http.Request, o metaOptions) (string, bool) {
+		if o.methodOverride && r.Method == http.MethodPost {
+			if m := strings.TrimSpace(r.Header.Get(methodOverrideHeader)); m != "" {
+				return strings.ToUpper(m), true
+			}
+		}
+		return r.Method, r.Method != ""
+	},
+	"path": func(r *http.Request, _ metaOptions) (string, bool) {
+		if r.URL == nil {
+			return "", false
+		}
+		return r.URL.Path, true
+	},
+	"tls": func(r *http.Request, _ metaOptions) (string, bool) {
+		return strconv.FormatBool(r.TLS != nil), true
+	},
+	"client_ip": clientIP,
+}
+
+// clientIP returns address of the client, see WithTrustedProxies.
+// Without trusted proxies it is the host of r.RemoteAddr, as headers can be forged.
+// With n trusted proxies, X-Forwarded-For entries added by them are skipped from the right,
+// so the client is the n-th entry from the end, or the first one when there are fewer.
+// X-Real-IP is used when X-Forwarded-For is absent. Ports of entries are dropped.
+func clientIP(r *http.Request, o metaOptions) (string, bool) {
+	if o.trustedProxies > 0 {
+		var hops []string
+		for _, line := range r.Header.Values("X-Forwarded-For") {
+			for hop := range strings.SplitSeq(line, ",") {
+				if hop = strings.TrimSpace(hop); hop != "" {
+					hops = append(hops, hop)
+				}
+			}
+		}
+		if len(hops) > 0 {
+			return stripPort(hops[max(len(hops)-o.trustedProxies, 0)]), true
+		}
+		if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); ip != "" {
+			return stripPort(ip), true
+		}
+	}
+	if r.RemoteAddr == "" {
+		return "", false
+	}
+	return stripPort(r.RemoteAddr), true
+}
+
+// stripPort returns host of "ip:port" and "[ipv6]:port" addresses, other values as is.
+func stripPort(addr string) string {
+	if ap, err := netip.ParseAddrPort(addr); err == nil {
+		return ap.Addr().String()
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func unmarshalMeta(
+	r *http.Request,
+	fields map[string]compiledField,
+	st *decodeState,
+	o metaOptions,
+) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	for key, cf := range fields {
+		v, ok := metaValues[key](r, o)
+		if !ok {
+			continue
+		}
+		if err := st.set(cf, []string{v}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,630 @@
+package httpio
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fieldOptions are modifiers following the name in a tag, e.g. `query:"tags,csv"`.
+type fieldOptions struct {
+	// sep splits every incoming value of a slice field.
+	// Splitting is naive: there is no quoting or escaping,
+	// and empty elements are kept as is.
+	sep string
+	// delim overrides delimiter of names inside nested struct field, e.g. name-first.
+	delim string
+	// required fields must be present in the request.
+	required bool
+	// defaultValue is used when the field is absent from its source.
+	// It can't contain modifier separator: "," in source tags and ";" in in tags.
+	defaultValue *string
+	// oneof lists allowed values, separated by spaces in the tag.
+	oneof []string
+	// trueValue makes bool field true only when value equals it, false otherwise.
+	// Set by truevalue modifier or its alias equals, e.g. `header:"X-Feature,equals=beta"`.
+	trueValue *string
+	// truthy makes bool field accept on/off, yes/no and y/n besides strconv.ParseBool values.
+	truthy bool
+	// base of integer values, 0 detects it from prefix like 0x, 0o, 0 or 0b.
+	// Nil means base 10.
+	base *int
+	// plus lets unsigned integer fields accept "+" sign, signed ones always do.
+	plus bool
+	// char sets rune and byte fields from a single character instead of a number,
+	// e.g. ?sep=, becomes ',' rather than a parse error.
+	char bool
+	// layout parses time.Time, sql.NullTime and sql.Null[time.Time] fields
+	// with time.Parse instead of RFC 3339, e.g. `query:"day,layout=2006-01-02"`.
+	layout string
+	// unixUnit parses time fields as integer Unix time: time.Second for unix modifier,
+	// time.Millisecond for unixmilli, zero otherwise.
+	unixUnit time.Duration
+	// json decodes the value as JSON into the whole field, e.g. `query:"ids,json"` for ?ids=[1,2,3].
+	// Structs are not expanded into nested fields then.
+	json bool
+	// raw copies value bytes into []byte field without parsing.
+	raw bool
+	// base64 decodes standard base64 value into []byte field.
+	base64 bool
+	// unique drops repeated slice elements, keeping the first occurrence.
+	unique bool
+	// maxLen limits number of slice elements, checked after unique.
+	maxLen int
+	// maxValues is set from WithQueryArrayLimit rather than a tag, it limits number
+	// of slice elements before they are parsed.
+	maxValues int
+	// unmapped limits `query:"*"` field to keys without their own fields.
+	unmapped bool
+	// trim, lower and upper normalize every value before it is parsed,
+	// so " 30 " trims to "30" for int fields too.
+	trim  bool
+	lower bool
+	upper bool
+	// authScheme keeps only credentials of values with this scheme, e.g. "Bearer",
+	// values with other schemes are treated as absent.
+	authScheme string
+	// basicAuth decodes base64 credentials of Basic scheme into user:pass.
+	basicAuth bool
+}
+
+// parseTag splits tag into name and modifiers separated by modSep:
+// "," for source tags and ";" for in tags.
+func parseTag(tag, modSep string) (string, fieldOptions, error) {
+	var opts fieldOptions
+	name, rest, _ := strings.Cut(tag, modSep)
+	for rest != "" {
+		var mod string
+		mod, rest, _ = strings.Cut(rest, modSep)
+		key, value, _ := strings.Cut(mod, "=")
+		switch key {
+		case "required":
+			opts.required = true
+		case "csv":
+			opts.sep = ","
+		case "split":
+			// for path wildcards, e.g. {rest...}
+			opts.sep = "/"
+		case "sep":
+			if value == "" {
+				return "", opts, errors.New("sep modifier requires a value")
+			}
+			opts.sep = value
+		case "delim":
+			if value == "" {
+				return "", opts, errors.New("delim modifier requires a value")
+			}
+			opts.delim = value
+		case "default":
+			opts.defaultValue = &value
+		case "oneof":
+			opts.oneof = strings.Fields(value)
+			if len(opts.oneof) == 0 {
+				return "", opts, errors.New("oneof modifier requires at least one value")
+			}
+		case "layout":
+			if value == "" {
+				return "", opts, errors.New("layout modifier requires a value")
+			}
+			opts.layout = value
+		case "unix":
+			opts.unixUnit = time.Second
+		case "unixmilli":
+			opts.unixUnit = time.Millisecond
+		case "json":
+			opts.json = true
+		case "raw":
+			opts.raw = true
+		case "base64":
+			opts.base64 = true
+		case "unique":
+			opts.unique = true
+		case "unmapped":
+			opts.unmapped = true
+		case "bearer":
+			opts.authScheme = "Bearer"
+		case "basic":
+			opts.authScheme = "Basic"
+			opts.basicAuth = true
+		case "scheme":
+			if value == "" {
+				return "", opts, errors.New("scheme modifier requires a value")
+			}
+			opts.authScheme = value
+		case "char":
+			opts.char = true
+		case "trim":
+			opts.trim = true
+		case "lower":
+			opts.lower = true
+		case "upper":
+			opts.upper = true
+		case "max":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return "", opts, fmt.Errorf("max modifier requires a positive number, got %q", value)
+			}
+			opts.maxLen = n
+		case "base":
+			n, err := strconv.Atoi(value)
+			if err != nil || n == 1 || n < 0 || n > 36 {
+				return "", opts, fmt.Errorf("base modifier requires 0 or a number from 2 to 36, got %q", value)
+			}
+			opts.base = &n
+		case "truthy":
+			opts.truthy = true
+		case "plus":
+			opts.plus = true
+		case "truevalue", "equals":
+			opts.trueValue = &value
+		default:
+			return "", opts, fmt.Errorf("unknown tag modifier %q", key)
+		}
+	}
+	if opts.required && opts.defaultValue != nil {
+		return "", opts, errors.New("required and default modifiers are mutually exclusive")
+	}
+	if opts.lower && opts.upper {
+		return "", opts, errors.New("lower and upper modifiers are mutually exclusive")
+	}
+	if opts.authScheme != "" && opts.defaultValue != nil {
+		return "", opts, errors.New("default modifier can't be combined with auth scheme modifiers")
+	}
+	return name, opts, nil
+}
+
+// findTag looks up source tags, e.g. `query:"name,required"`,
+// falling back to the single tag style, e.g. `in:"query=name;required"`.
+// It returns tag without source prefix and the separator of its modifiers.
+// Several source tags form a fallback chain handled by sourceChain,
+// other combinations are reported as a conflict.
+func findTag(t reflect.StructField) (string, string, tagType, bool, error) {
+	if chain, err := sourceChain(t); err != nil || chain != "" {
+		return "", ",", 0, false, err
+	}
+	var (
+		tag   string
+		found tagType
+		names []string
+	)
+	for _, src := range allSources {
+		if v, ok := t.Tag.Lookup(src.String()); ok && v != "" {
+			tag, found = v, src
+			names = append(names, src.String())
+		}
+	}
+	inTag, hasIn := t.Tag.Lookup("in")
+	if hasIn && inTag != "" {
+		names = append(names, "in")
+	}
+	if t.Tag.Get("from") != "" {
+		if len(names) > 0 {
+			names = append(names, "from")
+		} else {
+			// handled by parseFromTag
+			return "", ",", 0, false, nil
+		}
+	}
+	switch {
+	case len(names) > 1:
+		return "", "", 0, false, fmt.Errorf("conflicting source tags: %s", strings.Join(names, ", "))
+	case len(names) == 0:
+		return "", ",", 0, false, nil
+	case hasIn && inTag != "":
+		return parseInTag(inTag)
+	}
+	return tag, ",", found, true, nil
+}
+
+func parseInTag(tag string) (string, string, tagType, bool, error) {
+	srcName, rest, ok := strings.Cut(tag, "=")
+	if !ok || rest == "" || strings.HasPrefix(rest, ";") {
+		return "", "", 0, false, fmt.Errorf("in tag %q: expected source=name", tag)
+	}
+	if src, ok := sourceByName(srcName); ok {
+		return rest, ";", src, true, nil
+	}
+	return "", "", 0, false, fmt.Errorf("in tag %q: unknown source %q", tag, srcName)
+}
+
+// fallbackSource is one entry of `from:"query=token;header=X-Token"` tag.
+type fallbackSource struct {
+	src  tagType
+	name string
+}
+
+// fallbackSources can be tried by name for every request.
+var fallbackSources = []tagType{tagTypeQuery, tagTypeForm, tagTypePath, tagTypeHeader, tagTypeCookie}
+
+// parseFromTag splits `from` tag into ordered sources and modifiers, e.g.
+// `from:"query=token;header=X-Token;cookie=token;required"`.
+// Names are used as is, nesting prefixes don't apply.
+func parseFromTag(tag string) ([]fallbackSource, fieldOptions, error) {
+	var (
+		chain []fallbackSource
+		mods  []string
+	)
+	for part := range strings.SplitSeq(tag, ";") {
+		if part == "" {
+			continue
+		}
+		key, name, _ := strings.Cut(part, "=")
+		src, ok := sourceByName(key)
+		if !ok {
+			mods = append(mods, part)
+			continue
+		}
+		if !slices.Contains(fallbackSources, src) {
+			return nil, fieldOptions{}, fmt.Errorf("from tag %q: source %s is not supported", tag, key)
+		}
+		if name == "" {
+			return nil, fieldOptions{}, fmt.Errorf("from tag %q: expected source=name", tag)
+		}
+		chain = append(chain, fallbackSource{src: src, name: name})
+	}
+	if len(chain) == 0 {
+		return nil, fieldOptions{}, fmt.Errorf("from tag %q: no sources", tag)
+	}
+	_, opts, err := parseTag(";"+strings.Join(mods, ";"), ";")
+	if err != nil {
+		return nil, opts, err
+	}
+	return chain, opts, nil
+}
+
+// sourceChain turns several source tags on one field, e.g.
+// `header:"X-Request-ID" query:"request_id"`, into a `from` tag
+// that tries them in the order they are written.
+// It returns empty string when the field doesn't have such tags.
+// Modifiers may be set on any of the tags, but must not differ between them.
+func sourceChain(t reflect.StructField) (string, error) {
+	if t.Tag.Get("from") != "" || t.Tag.Get("in") != "" {
+		return "", nil
+	}
+	var (
+		parts []string
+		mods  string
+		first string
+	)
+	for _, key := range tagKeys(t.Tag) {
+		src, ok := sourceByName(key)
+		if !ok {
+			continue
+		}
+		v := t.Tag.Get(key)
+		if v == "" {
+			continue
+		}
+		if !slices.Contains(fallbackSources, src) {
+			return "", nil
+		}
+		name, m, _ := strings.Cut(v, ",")
+		if name == "" {
+			name = t.Name
+		}
+		parts = append(parts, key+"="+name)
+		if m == "" {
+			continue
+		}
+		if mods != "" && m != mods {
+			return "", fmt.Errorf("%s and %s tags have different modifiers", first, key)
+		}
+		mods, first = m, key
+	}
+	if len(parts) < 2 {
+		return "", nil
+	}
+	if mods != "" {
+		parts = append(parts, strings.Split(mods, ",")...)
+	}
+	return strings.Join(parts, ";"), nil
+}
+
+// tagKeys returns keys of tag in the order they are written.
+func tagKeys(tag reflect.StructTag) []string {
+	var keys []string
+	s := string(tag)
+	for s != "" {
+		s = strings.TrimLeft(s, " ")
+		i := strings.IndexByte(s, ':')
+		if i <= 0 || i+1 >= len(s) || s[i+1] != '"' {
+			break
+		}
+		key := s[:i]
+		s = s[i+2:]
+		// skip quoted value, honoring escapes
+		j := 0
+		for j < len(s) && s[j] != '"' {
+			if s[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(s) {
+			break
+		}
+		keys = append(keys, key)
+		s = s[j+1:]
+	}
+	return keys
+}
+
+func sourceByName(name string) (tagType, bool) {
+	for _, src := range allSources {
+		if src.String() == name {
+			return src, true
+		}
+	}
+	return 0, false
+}
+
+// makeFieldSetter builds setter for ft and wraps it with validations requested by opts.
+// Default and oneof values are checked against ft here, so bad tags fail at compile time.
+func makeFieldSetter(ft reflect.Type, opts fieldOptions) (valueSetterFunc, error) {
+	if opts.trueValue != nil && scalarType(ft).Kind() != reflect.Bool {
+		return nil, fmt.Errorf("truevalue and equals modifiers require bool type, got %v", ft)
+	}
+
+	if opts.base != nil {
+		switch scalarType(ft).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		default:
+			return nil, fmt.Errorf("base modifier requires integer type, got %v", ft)
+		}
+	}
+
+	if opts.plus {
+		switch scalarType(ft).Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+			reflect.Float32, reflect.Float64:
+		default:
+			return nil, fmt.Errorf("plus modifier requires number type, got %v", ft)
+		}
+		if opts.char {
+			return nil, errors.New("char and plus modifiers are mutually exclusive")
+		}
+	}
+
+	for _, m := range []struct {
+		name string
+		set  bool
+	}{{"layout modifier requires", opts.layout != ""}, {"unix and unixmilli modifiers require", opts.unixUnit != 0}} {
+		if m.set && !isTimeType(scalarType(ft)) {
+			return nil, fmt.Errorf("%s time.Time, sql.NullTime or sql.Null[time.Time] type, got %v", m.name, ft)
+		}
+	}
+	if opts.layout != "" && opts.unixUnit != 0 {
+		return nil, errors.New("layout modifier can't be combined with unix and unixmilli modifiers")
+	}
+
+	if opts.char {
+		if k := scalarType(ft).Kind(); k != reflect.Int32 && k != reflect.Uint8 {
+			return nil, fmt.Errorf("char modifier requires rune or byte type, got %v", ft)
+		}
+		if opts.base != nil {
+			return nil, errors.New("char and base modifiers are mutually exclusive")
+		}
+	}
+
+	if opts.truthy {
+		if scalarType(ft).Kind() != reflect.Bool {
+			return nil, fmt.Errorf("truthy modifier requires bool type, got %v", ft)
+		}
+		if opts.trueValue != nil {
+			return nil, errors.New("truthy modifier can't be combined with truevalue and equals modifiers")
+		}
+	}
+
+	for _, m := range []struct {
+		name string
+		set  bool
+	}{{"raw", opts.raw}, {"base64", opts.base64}} {
+		if !m.set {
+			continue
+		}
+		if under := derefType(ft); under.Kind() != reflect.Slice || under.Elem().Kind() != reflect.Uint8 {
+			return nil, fmt.Errorf("%s modifier requires []byte type, got %v", m.name, ft)
+		}
+		if opts.sep != "" {
+			return nil, fmt.Errorf("%s modifier can't be combined with separator modifiers", m.name)
+		}
+	}
+	if opts.json && (opts.sep != "" || opts.raw || opts.base64 || opts.char) {
+		return nil, errors.New("json modifier can't be combined with separator, raw, base64 and char modifiers")
+	}
+	if opts.raw && opts.base64 {
+		return nil, errors.New("raw and base64 modifiers are mutually exclusive")
+	}
+
+	set, err := makeValueSetter(ft, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.authScheme != "" {
+		set = withAuthScheme(set, opts.authScheme, opts.basicAuth)
+	}
+
+	if len(opts.oneof) > 0 {
+		elem := scalarType(ft)
+		if !elem.Comparable() {
+			return nil, fmt.Errorf("oneof modifier requires comparable type, got %v", elem)
+		}
+		scalar, err := makeScalarSetter(elem, opts)
+		if err != nil {
+			return nil, err
+		}
+		allowed := make([]reflect.Value, 0, len(opts.oneof))
+		for _, s := range opts.oneof {
+			v := reflect.New(elem).Elem()
+			if err := scalar(v, s); err != nil {
+				return nil, fmt.Errorf("oneof value %q: %w", s, err)
+			}
+			allowed = append(allowed, v)
+		}
+		set = withOneOf(set, allowed, opts.oneof)
+	}
+
+	if opts.unique || opts.maxLen > 0 {
+		if derefType(ft).Kind() != reflect.Slice {
+			return nil, fmt.Errorf("unique and max modifiers require a slice, got %v", ft)
+		}
+		if opts.unique && !derefType(derefType(ft).Elem()).Comparable() {
+			return nil, fmt.Errorf("unique modifier requires comparable elements, got %v", ft)
+		}
+		set = withSliceLimits(set, opts.unique, opts.maxLen)
+	}
+
+	if opts.defaultValue != nil {
+		if err := set(reflect.New(ft).Elem(), []string{*opts.defaultValue}); err != nil {
+			return nil, fmt.Errorf("default value %q: %w", *opts.defaultValue, err)
+		}
+	}
+
+	return set, nil
+}
+
+// withAuthScheme strips scheme from credentials like "Bearer token",
+// matching the scheme case-insensitively as RFC 9110 requires.
+func withAuthScheme(set valueSetterFunc, scheme string, basic bool) valueSetterFunc {
+	return func(v reflect.Value, vals []string) error {
+		creds := make([]string, 0, len(vals))
+		for _, val := range vals {
+			s, c, ok := strings.Cut(strings.TrimSpace(val), " ")
+			if !ok || !strings.EqualFold(s, scheme) {
+				continue
+			}
+			c = strings.TrimSpace(c)
+			if basic {
+				decoded, err := base64.StdEncoding.DecodeString(c)
+				if err != nil || !strings.Contains(string(decoded), ":") {
+					return errors.New("malformed basic credentials")
+				}
+				c = string(decoded)
+			}
+			if c != "" {
+				creds = append(creds, c)
+			}
+		}
+		if len(creds) == 0 {
+			return errValueAbsent
+		}
+		return set(v, creds)
+	}
+}
+
+// withNormalize applies trim, lower and upper modifiers before scalar parses s.
+func withNormalize(scalar func(reflect.Value, string) error, opts fieldOptions) func(reflect.Value, string) error {
+	return func(v reflect.Value, s string) error {
+		if opts.trim {
+			s = strings.TrimSpace(s)
+		}
+		switch {
+		case opts.lower:
+			s = strings.ToLower(s)
+		case opts.upper:
+			s = strings.ToUpper(s)
+		}
+		return scalar(v, s)
+	}
+}
+
+func derefType(ft reflect.Type) reflect.Type {
+	if ft.Kind() == reflect.Pointer {
+		return ft.Elem()
+	}
+	return ft
+}
+
+// scalarType strips pointers, slices and arrays from ft.
+func scalarType(ft reflect.Type) reflect.Type {
+	for ft.Kind() == reflect.Pointer || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array {
+		ft = ft.Elem()
+	}
+	return ft
+}
+
+// withSliceLimits runs after splitting, element parsing and oneof checks:
+// duplicates are removed first, keeping the first occurrence, then number of elements is checked.
+// Huge inputs are bounded before parsing by WithQueryArrayLimit instead.
+func withSliceLimits(set valueSetterFunc, unique bool, maxLen int) valueSetterFunc {
+	return func(v reflect.Value, vals []string) error {
+		if err := set(v, vals); err != nil {
+			return err
+		}
+		s := v
+		if s.Kind() == reflect.Pointer {
+			if s.IsNil() {
+				return nil
+			}
+			s = s.Elem()
+		}
+		if unique {
+			dedup(s)
+		}
+		if maxLen > 0 && s.Len() > maxLen {
+			return fmt.Errorf("expected at most %d values, got %d", maxLen, s.Len())
+		}
+		return nil
+	}
+}
+
+// dedup removes repeated elements of slice s in place, keeping the first occurrence.
+func dedup(s reflect.Value) {
+	seen := make(map[any]struct{}, s.Len())
+	n := 0
+	for i := range s.Len() {
+		elem := s.Index(i)
+		// pointer elements are compared by values they point to
+		var key any
+		if e := reflect.Indirect(elem); e.IsValid() {
+			key = e.Interface()
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		if n != i {
+			s.Index(n).Set(elem)
+		}
+		n++
+	}
+	s.SetLen(n)
+}
+
+func withOneOf(set valueSetterFunc, allowed []reflect.Value, names []string) valueSetterFunc {
+	var check func(v reflect.Value) error
+	check = func(v reflect.Value) error {
+		switch v.Kind() {
+		case reflect.Pointer:
+			if v.IsNil() {
+				return nil
+			}
+			return check(v.Elem())
+		case reflect.Slice, reflect.Array:
+			for i := range v.Len() {
+				if err := check(v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if !slices.ContainsFunc(allowed, v.Equal) {
+			return fmt.Errorf("value %v is not one of [%s]", v, strings.Join(names, " "))
+		}
+		return nil
+	}
+
+	return func(v reflect.Value, vals []string) error {
+		if err := set(v, vals); err != nil {
+			return err
+		}
+		return check(v)
+	}
+}
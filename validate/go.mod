@@ -0,0 +1,21 @@
+module github.com/pechorka/httpio/validate
+
+go 1.25.0
+
+replace github.com/pechorka/httpio => ..
+
+require (
+	github.com/go-playground/validator/v10 v10.26.0
+	github.com/pechorka/httpio v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/gabriel-vasile/mimetype v1.4.8 // indirect
+	github.com/go-playground/locales v0.14.1 // indirect
+	github.com/go-playground/universal-translator v0.18.1 // indirect
+	github.com/leodido/go-urn v1.4.0 // indirect
+	golang.org/x/crypto v0.33.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
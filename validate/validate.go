@@ -0,0 +1,33 @@
+// Package validate pairs httpio decoding with struct validation libraries,
+// such as github.com/go-playground/validator, whose *validator.Validate
+// satisfies StructValidator as is. It is a separate module, so httpio stays
+// dependency-free, and the package itself imports nothing but httpio,
+// so the validation library is chosen by callers.
+package validate
+
+import (
+	"net/http"
+
+	"github.com/pechorka/httpio"
+)
+
+// StructValidator validates struct fields, e.g. by their validate tags.
+type StructValidator interface {
+	Struct(s any) error
+}
+
+// BindAndValidate decodes r into a new value of T with default options,
+// then validates it with v. Decode errors are returned as is and skip validation,
+// validation errors are returned as is too, so errors.As finds library types,
+// e.g. validator.ValidationErrors.
+// The Unmarshaler is built on first call for T and reused afterwards, like in httpio.Unmarshal.
+func BindAndValidate[T any](r *http.Request, v StructValidator) (T, error) {
+	var dst T
+	if err := httpio.Unmarshal(r, &dst); err != nil {
+		return dst, err
+	}
+	if err := v.Struct(&dst); err != nil {
+		return dst, err
+	}
+	return dst, nil
+}
@@ -0,0 +1,47 @@
+package validate_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/pechorka/httpio/validate"
+)
+
+var _ validate.StructValidator = (*validator.Validate)(nil)
+
+func TestBindAndValidate(t *testing.T) {
+	type input struct {
+		Email string `query:"email" validate:"email"`
+		Age   int    `query:"age"`
+	}
+
+	v := validator.New()
+
+	t.Run("valid", func(t *testing.T) {
+		got, err := validate.BindAndValidate[input](httptest.NewRequest("GET", "/?email=john@example.com&age=30", nil), v)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != (input{Email: "john@example.com", Age: 30}) {
+			t.Fatalf("unexpected value: %+v", got)
+		}
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		_, err := validate.BindAndValidate[input](httptest.NewRequest("GET", "/?email=john&age=30", nil), v)
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field() != "Email" || verrs[0].Tag() != "email" {
+			t.Fatalf("expected email validation error, got %v", err)
+		}
+	})
+
+	t.Run("decode error skips validation", func(t *testing.T) {
+		_, err := validate.BindAndValidate[input](httptest.NewRequest("GET", "/?email=john&age=abc", nil), v)
+		var verrs validator.ValidationErrors
+		if err == nil || errors.As(err, &verrs) {
+			t.Fatalf("expected decode error, got %v", err)
+		}
+	})
+}